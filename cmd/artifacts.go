@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Dids/clobber/util"
+	"github.com/mholt/archiver"
+	"github.com/spf13/cobra"
+)
+
+// PruneKeep is the number of newest artifacts to keep when pruning
+var PruneKeep int
+
+// PruneOlderThan only prunes artifacts older than this age (eg. "30d")
+var PruneOlderThan string
+
+// PruneDryRun only lists the artifacts that would be pruned
+var PruneDryRun bool
+
+// artifactsCmd is the parent command for managing build artifacts
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "Manage build artifacts",
+	Long: `Manage build artifacts.
+
+Every build copies its installer package, ISO image and zipped EFI folder,
+along with a build manifest and SHA256SUMS, to a directory in the artifact
+store (the workspace's "artifacts" directory, or --output if set).
+Artifacts are referred to by their directory name, or a unique prefix of it.`,
+}
+
+// artifactsListCmd lists all artifacts in the store
+var artifactsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all artifacts",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		artifacts, err := util.ListArtifacts(getOutputPath())
+		if err != nil {
+			log.Fatal("Error: Failed to list artifacts: ", err)
+		}
+		if len(artifacts) == 0 {
+			fmt.Println("No artifacts in " + getOutputPath())
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(writer, "ID\tREVISION\tTOOLCHAIN\tSIZE\tDATE\tSTATUS")
+		for _, artifact := range artifacts {
+			revision, toolchain := "-", "-"
+			if artifact.Info != nil {
+				revision, toolchain = artifact.Info.Clover.Describe, artifact.Info.Toolchain
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", artifact.ID, revision, toolchain, util.FormatSize(artifact.Size), artifact.Created.Format("2006-01-02 15:04"), artifact.Status())
+		}
+		writer.Flush()
+	},
+}
+
+// artifactsShowCmd shows the details and files of a single artifact
+var artifactsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the details of an artifact",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		artifact := getArtifact(args[0])
+
+		fmt.Println("ID:      " + artifact.ID)
+		fmt.Println("Path:    " + artifact.Path)
+		fmt.Println("Size:    " + util.FormatSize(artifact.Size))
+		fmt.Println("Status:  " + artifact.Status())
+		if artifact.Info != nil {
+			fmt.Println()
+			for _, line := range artifact.Info.Summary() {
+				fmt.Println(line)
+			}
+		}
+
+		fileInfos, err := ioutil.ReadDir(artifact.Path)
+		if err != nil {
+			log.Fatal("Error: Failed to list artifact files: ", err)
+		}
+		fmt.Println()
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, fileInfo := range fileInfos {
+			fmt.Fprintf(writer, "%s\t%s\n", fileInfo.Name(), util.FormatSize(fileInfo.Size()))
+		}
+		writer.Flush()
+	},
+}
+
+// artifactsVerifyCmd validates artifacts against their SHA256SUMS
+var artifactsVerifyCmd = &cobra.Command{
+	Use:   "verify [id...]",
+	Short: "Verify artifacts against their checksums (all artifacts if no id is given)",
+	Run: func(cmd *cobra.Command, args []string) {
+		var artifacts []*util.Artifact
+		if len(args) == 0 {
+			var err error
+			if artifacts, err = util.ListArtifacts(getOutputPath()); err != nil {
+				log.Fatal("Error: Failed to list artifacts: ", err)
+			}
+		}
+		for _, id := range args {
+			artifacts = append(artifacts, getArtifact(id))
+		}
+
+		failed := 0
+		for _, artifact := range artifacts {
+			problems, err := util.VerifyArtifact(artifact.Path)
+			if err != nil {
+				problems = []string{err.Error()}
+			}
+			if len(problems) == 0 {
+				fmt.Println(artifact.ID + ": OK")
+				continue
+			}
+			failed++
+			fmt.Println(artifact.ID + ": FAILED")
+			for _, problem := range problems {
+				fmt.Println("  " + problem)
+			}
+		}
+		if failed > 0 {
+			log.Fatalf("Error: %d of %d artifacts failed verification", failed, len(artifacts))
+		}
+	},
+}
+
+// artifactsOpenCmd opens an artifact directory in the file manager
+var artifactsOpenCmd = &cobra.Command{
+	Use:   "open <id>",
+	Short: "Open an artifact in the file manager",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		artifact := getArtifact(args[0])
+		opener := "xdg-open"
+		if runtime.GOOS == "darwin" {
+			opener = "open"
+		}
+		if err := exec.Command(opener, artifact.Path).Run(); err != nil {
+			log.Fatal("Error: Failed to open "+artifact.Path+": ", err)
+		}
+	},
+}
+
+// artifactsPruneCmd removes old artifacts from the store
+var artifactsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old artifacts",
+	Long: `Remove old artifacts, keeping the newest --keep artifacts and anything
+newer than --older-than (eg. "30d", "2w" or "12h"). When both are given,
+artifacts are only removed if they match both.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if PruneKeep < 0 && len(PruneOlderThan) == 0 {
+			log.Fatal("Error: Either --keep or --older-than is required")
+		}
+		var olderThan time.Duration
+		if len(PruneOlderThan) > 0 {
+			var err error
+			if olderThan, err = util.ParseAge(PruneOlderThan); err != nil {
+				log.Fatal("Error: ", err)
+			}
+		}
+
+		artifacts, err := util.ListArtifacts(getOutputPath())
+		if err != nil {
+			log.Fatal("Error: Failed to list artifacts: ", err)
+		}
+
+		// Incomplete artifacts may belong to a build that is still running
+		_, buildRunning := util.IsLocked(util.GetLockPath())
+
+		removed := 0
+		for _, artifact := range util.SelectArtifactsToPrune(artifacts, PruneKeep, olderThan, time.Now()) {
+			if buildRunning && !artifact.Complete() {
+				fmt.Println("Skipping " + artifact.ID + " (a build is in progress)")
+				continue
+			}
+			if PruneDryRun {
+				fmt.Println("Would remove " + artifact.ID + " (" + util.FormatSize(artifact.Size) + ")")
+				continue
+			}
+			if err := os.RemoveAll(artifact.Path); err != nil {
+				log.Fatal("Error: Failed to remove artifact "+artifact.ID+": ", err)
+			}
+			fmt.Println("Removed " + artifact.ID + " (" + util.FormatSize(artifact.Size) + ")")
+			removed++
+		}
+		if !PruneDryRun {
+			fmt.Printf("Removed %d of %d artifacts\n", removed, len(artifacts))
+		}
+	},
+}
+
+// artifactsExportCmd copies a verified artifact to another directory
+var artifactsExportCmd = &cobra.Command{
+	Use:   "export <id> <dir>",
+	Short: "Verify an artifact and copy it to another directory",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		artifact := getArtifact(args[0])
+		problems, err := util.VerifyArtifact(artifact.Path)
+		if err != nil {
+			log.Fatal("Error: Failed to verify artifact "+artifact.ID+": ", err)
+		}
+		if len(problems) > 0 {
+			log.Fatal("Error: Artifact " + artifact.ID + " failed verification: " + strings.Join(problems, ", "))
+		}
+
+		exportPath := filepath.Join(args[1], artifact.ID)
+		if _, err := os.Stat(exportPath); err == nil {
+			log.Fatal("Error: " + exportPath + " already exists")
+		}
+		if err := os.MkdirAll(exportPath, 0755); err != nil {
+			log.Fatal("Error: Failed to create export directory: ", err)
+		}
+		if err := util.CopyFiles(artifact.Path, exportPath); err != nil {
+			log.Fatal("Error: Failed to export artifact: ", err)
+		}
+
+		// Make sure the copy is intact as well
+		if problems, err := util.VerifyArtifact(exportPath); err != nil || len(problems) > 0 {
+			log.Fatal("Error: Exported artifact failed verification: ", err, problems)
+		}
+		fmt.Println("Exported " + artifact.ID + " to " + exportPath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(artifactsCmd)
+	artifactsCmd.AddCommand(artifactsListCmd)
+	artifactsCmd.AddCommand(artifactsShowCmd)
+	artifactsCmd.AddCommand(artifactsVerifyCmd)
+	artifactsCmd.AddCommand(artifactsOpenCmd)
+	artifactsCmd.AddCommand(artifactsPruneCmd)
+	artifactsCmd.AddCommand(artifactsExportCmd)
+	artifactsPruneCmd.Flags().IntVarP(&PruneKeep, "keep", "k", -1, "number of newest artifacts to keep")
+	artifactsPruneCmd.Flags().StringVarP(&PruneOlderThan, "older-than", "", "", "only remove artifacts older than this (eg. 30d)")
+	artifactsPruneCmd.Flags().BoolVarP(&PruneDryRun, "dry-run", "", false, "only show what would be removed")
+}
+
+// getArtifact returns an artifact from the store, or exits if it doesn't exist
+func getArtifact(id string) *util.Artifact {
+	artifact, err := util.GetArtifact(getOutputPath(), id)
+	if err != nil {
+		log.Fatal("Error: ", err)
+	}
+	return artifact
+}
+
+// getOutputPath returns the directory containing all build artifacts
+func getOutputPath() string {
+	if len(Output) > 0 {
+		outputPath, err := filepath.Abs(Output)
+		if err != nil {
+			log.Fatal("Error: Failed to resolve --output: ", err)
+		}
+		return outputPath
+	}
+	return util.GetArtifactsPath()
+}
+
+// collectArtifacts copies the installer package, the ISO image and a zipped
+// EFI tree to a new versioned artifact directory, returning its path
+func collectArtifacts(info *util.BuildInfo) string {
+	artifactPath := filepath.Join(getOutputPath(), util.GetArtifactName(info))
+	if err := os.MkdirAll(artifactPath, 0755); err != nil {
+		log.Fatal("Error: Failed to create artifact directory: ", err)
+	}
+
+	// Copy the installer package(s) and ISO image(s), leaving out any left over from earlier builds
+	symPath := util.GetCloverPath() + "/CloverPackage/sym"
+	for _, artifact := range util.FindFiles(info.Started, symPath+"/*.pkg", symPath+"/*.iso", symPath+"/*/*.iso") {
+		copiedArtifact, err := util.CopyArtifact(artifact, artifactPath)
+		if err != nil {
+			log.Fatal("Error: Failed to collect artifact ", artifact, ": ", err)
+		}
+		log.Debug("Collected artifact " + copiedArtifact)
+	}
+
+	// Zip the EFI tree
+	efiPath := util.GetCloverPath() + "/CloverPackage/CloverV2/EFI"
+	if _, err := os.Stat(efiPath); err == nil {
+		efiZipPath := filepath.Join(artifactPath, "CloverV2-EFI.zip")
+		if err := archiver.Archive([]string{efiPath}, efiZipPath); err != nil {
+			log.Fatal("Error: Failed to zip the EFI tree: ", err)
+		}
+		log.Debug("Collected artifact " + efiZipPath)
+	} else {
+		printWarning("EFI tree is missing from " + efiPath)
+	}
+
+	return artifactPath
+}
+
+// printArtifacts prints the paths of all files in an artifact directory
+func printArtifacts(artifactPath string) {
+	fileInfos, err := ioutil.ReadDir(artifactPath)
+	if err != nil {
+		log.Warn("Warning: Failed to list artifacts: ", err)
+		return
+	}
+	log.Info("Artifacts saved to " + artifactPath)
+	if Quiet {
+		return
+	}
+	fmt.Println("Artifacts saved to " + artifactPath + ":")
+	for _, fileInfo := range fileInfos {
+		fmt.Println("  " + filepath.Join(artifactPath, fileInfo.Name()))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Dids/clobber/util"
+	logrus "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// ebuildCmd runs ebuild.sh with arbitrary arguments
+var ebuildCmd = &cobra.Command{
+	Use:   "ebuild [flags] -- [ebuild.sh arguments]",
+	Short: "Run ebuild.sh with custom arguments",
+	Long: `Prepare the toolchain, dependencies and EDK exactly like a normal build,
+then run ./ebuild.sh with the supplied arguments (eg. different -D defines or
+single package builds). The output is streamed and logged like a normal build.
+
+Unless the arguments contain -t/--tagname, "-t <toolchain>" is added automatically:
+
+  clobber ebuild -- -fr -D NO_GRUB_DRIVERS_EMBEDDED
+  clobber ebuild --toolchain GCC53 -- -fr --x64-mcp`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Measure execution time
+		executionStartTime := time.Now()
+
+		// Log this run to its own log file, before anything can fail
+		startRunLog(util.NewRunID(executionStartTime))
+
+		// The output of ebuild.sh is streamed to the terminal, which the game needs for itself
+		if Hiss {
+			log.Fatal("Error: Cannot use --hiss with ebuild")
+		}
+
+		// Make sure nothing else is using the workspace while we're building
+		workspaceLock := lockWorkspace()
+		defer workspaceLock.Release()
+
+		setupSourceDir()
+		requireClover()
+
+		// Record this run in the history, like a normal build
+		startRunRecord(executionStartTime)
+
+		// Prepare the environment for all build commands
+		buildEnv := util.NewBuildEnv(Toolchain, CleanEnv)
+		log.Debug("Build environment:\n" + buildEnv.String())
+
+		// Build base tools and prepare the toolchain and dependencies
+		pipeline = preparePipeline()
+		progress.Start()
+		logrus.RegisterExitHandler(func() { progress.Stop("") })
+		logrus.RegisterExitHandler(func() { workspaceLock.Release() })
+		cleanupBuild := prepareBuild(buildEnv)
+		defer cleanupBuild()
+		progress.Stop("")
+
+		// Default to the selected toolchain
+		if !hasToolchainArg(args) {
+			args = append([]string{"-t", Toolchain}, args...)
+		}
+		var quotedArgs []string
+		for _, arg := range args {
+			quotedArgs = append(quotedArgs, util.ShellQuote(arg))
+		}
+
+		// Run ebuild.sh, streaming its output unless we're supposed to be quiet
+		var output io.Writer = os.Stdout
+		if Quiet {
+			output = nil
+		}
+		beginStep("ebuild", "Running ebuild.sh")
+		log.Debug("Running ebuild.sh with arguments:", args)
+		if err := runCommandWithOutput("source edksetup.sh BaseTools; ./ebuild.sh "+strings.Join(quotedArgs, " "), util.GetCloverPath(), buildEnv, output); err != nil {
+			log.Fatal("Error: ebuild.sh failed, aborting")
+		}
+		endStep()
+
+		// Save the successful run to the history
+		finishRunRecord(util.RunSucceeded)
+
+		executionResult := fmt.Sprintf("\n🎉  Finished in %s 🎉\n", util.GenerateTimeString(time.Since(executionStartTime)))
+		log.Debug(executionResult)
+		if !Quiet {
+			fmt.Println(executionResult)
+		}
+	},
+}
+
+// hasToolchainArg returns true if the ebuild.sh arguments select a toolchain,
+// including the attached forms (eg. "-tXCODE8", "-t=XCODE8" or "--tagname=XCODE8")
+func hasToolchainArg(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-t") || strings.HasPrefix(arg, "--tagname") {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(ebuildCmd)
+}
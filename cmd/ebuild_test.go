@@ -0,0 +1,24 @@
+package cmd
+
+import "testing"
+
+func TestHasToolchainArg(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"-fr", "-D", "NO_GRUB_DRIVERS_EMBEDDED"}, false},
+		{[]string{"-fr", "--x64-mcp"}, false},
+		{[]string{"-t", "GCC53"}, true},
+		{[]string{"-tXCODE8"}, true},
+		{[]string{"-t=XCODE8"}, true},
+		{[]string{"--tagname", "GCC53"}, true},
+		{[]string{"--tagname=GCC53"}, true},
+	}
+	for _, test := range tests {
+		if hasToolchainArg(test.args) != test.expected {
+			t.Errorf("%v: expected %v", test.args, test.expected)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"github.com/Dids/clobber/util"
+	"github.com/spf13/cobra"
+)
+
+// envCmd prints the build environment as a sourceable script
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print a script for setting up the build environment",
+	Long: `Print a bash script for setting up the exact build environment Clobber uses
+(HOME, TOOLCHAIN_DIR, WORKSPACE, PATH etc.), which also changes to the Clover
+directory and sources edksetup.sh, so ebuild.sh can be run manually:
+
+  eval "$(clobber env)"
+  ./ebuild.sh -fr -t XCODE8`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setupSourceDir()
+		requireClover()
+		fmt.Print(buildEnvScript(util.NewBuildEnv(Toolchain, CleanEnv)))
+	},
+}
+
+// shellCmd opens an interactive shell in the build environment
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Open an interactive shell in the build environment",
+	Long: `Open an interactive bash shell in the Clover directory, with the exact build
+environment Clobber uses and edksetup.sh already sourced (see 'clobber env').`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setupSourceDir()
+		requireClover()
+		buildEnv := util.NewBuildEnv(Toolchain, CleanEnv)
+
+		// Use a custom rc file for setting up the shell
+		rcFile, err := ioutil.TempFile("", "clobber-shell.*.bashrc")
+		if err != nil {
+			log.Fatal("Error: Failed to create shell rc file: ", err)
+		}
+		defer os.Remove(rcFile.Name())
+		rcScript := buildEnvScript(buildEnv)
+		rcScript += "PS1=" + util.ShellQuote("(clobber:"+util.GetWorkspace()+") \\W \\$ ") + "\n"
+		if _, err := rcFile.WriteString(rcScript); err != nil {
+			log.Fatal("Error: Failed to write shell rc file: ", err)
+		}
+		rcFile.Close()
+
+		fmt.Println("Entering the Clobber build environment for " + util.GetCloverPath() + " (type 'exit' to leave)")
+		log.Debug("Starting shell with build environment:\n" + buildEnv.String())
+
+		// The shell handles CTRL-C on its own, so make sure it doesn't kill us
+		signal.Ignore(os.Interrupt)
+		defer signal.Reset(os.Interrupt)
+
+		shell := exec.Command("bash", "--rcfile", rcFile.Name(), "-i")
+		shell.Dir = util.GetCloverPath()
+		shell.Env = buildEnv.Environ()
+		shell.Stdin = os.Stdin
+		shell.Stdout = os.Stdout
+		shell.Stderr = os.Stderr
+		if err := shell.Run(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				log.Fatal("Error: Failed to run shell: ", err)
+			}
+		}
+	},
+}
+
+// buildEnvScript returns a bash script that sets up the build environment,
+// changes to the Clover directory and sources edksetup.sh
+func buildEnvScript(buildEnv *util.BuildEnv) string {
+	script := "# Clobber build environment (workspace: " + util.GetWorkspace() + ", toolchain: " + Toolchain + ")\n"
+	script += buildEnv.Script()
+	script += "cd " + util.ShellQuote(util.GetCloverPath()) + "\n"
+	script += "source ./edksetup.sh BaseTools\n"
+	return script
+}
+
+// requireClover exits with an error if Clover hasn't been downloaded yet
+func requireClover() {
+	if _, err := os.Stat(util.GetCloverPath() + "/edksetup.sh"); err != nil {
+		log.Fatal("Error: Clover is missing from " + util.GetCloverPath() + ", run 'clobber --update-only' first")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(shellCmd)
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dids/clobber/util"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// Supported output formats
+const (
+	textOutputFormat = "text"
+	jsonOutputFormat = "json"
+)
+
+// OutputFormat is either "text" (human readable) or "json" (newline-delimited events)
+var OutputFormat string
+
+// buildEvent is a single event emitted in the JSON output format
+type buildEvent struct {
+	Time     time.Time   `json:"time"`
+	Event    string      `json:"event"`
+	Step     string      `json:"step,omitempty"`
+	Title    string      `json:"title,omitempty"`
+	Duration float64     `json:"duration,omitempty"`
+	Command  string      `json:"command,omitempty"`
+	Dir      string      `json:"dir,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	Path     string      `json:"path,omitempty"`
+	SHA256   string      `json:"sha256,omitempty"`
+	Size     int64       `json:"size,omitempty"`
+	Summary  *runSummary `json:"summary,omitempty"`
+}
+
+// runSummary is the finished run in the summary event,
+// with durations in seconds like in the other events
+type runSummary struct {
+	ID           string        `json:"id"`
+	Workspace    string        `json:"workspace"`
+	Started      time.Time     `json:"started"`
+	Finished     time.Time     `json:"finished"`
+	Duration     float64       `json:"duration"`
+	Revision     string        `json:"revision,omitempty"`
+	Describe     string        `json:"describe,omitempty"`
+	SHA          string        `json:"sha,omitempty"`
+	Toolchain    string        `json:"toolchain"`
+	Flags        []string      `json:"flags"`
+	Outcome      string        `json:"outcome"`
+	FailedStep   string        `json:"failedStep,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Steps        []stepSummary `json:"steps"`
+	LogFile      string        `json:"logFile"`
+	ArtifactPath string        `json:"artifactPath,omitempty"`
+}
+
+// stepSummary is a finished step in the summary event
+type stepSummary struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+}
+
+// newRunSummary returns the summary of a finished run
+func newRunSummary(record *util.RunRecord) *runSummary {
+	summary := &runSummary{
+		ID:           record.ID,
+		Workspace:    record.Workspace,
+		Started:      record.Started,
+		Finished:     record.Finished,
+		Duration:     record.Duration().Seconds(),
+		Revision:     record.Revision,
+		Describe:     record.Describe,
+		SHA:          record.SHA,
+		Toolchain:    record.Toolchain,
+		Flags:        record.Flags,
+		Outcome:      record.Outcome,
+		FailedStep:   record.FailedStep,
+		Error:        record.Error,
+		Steps:        []stepSummary{},
+		LogFile:      record.LogFile,
+		ArtifactPath: record.ArtifactPath,
+	}
+	for _, step := range record.Steps {
+		summary.Steps = append(summary.Steps, stepSummary{ID: step.ID, Title: step.Title, Duration: step.Duration.Seconds()})
+	}
+	return summary
+}
+
+// eventsOutput is where events are written to
+var eventsOutput io.Writer = os.Stdout
+
+// eventsMutex keeps events from different goroutines from being interleaved
+var eventsMutex sync.Mutex
+
+// jsonOutput returns true if events should be emitted instead of human readable output
+func jsonOutput() bool {
+	return OutputFormat == jsonOutputFormat
+}
+
+// emitEvent writes an event to stdout as a single line of JSON (only in the JSON output format)
+func emitEvent(event buildEvent) {
+	if !jsonOutput() {
+		return
+	}
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+	event.Time = time.Now()
+	if err := json.NewEncoder(eventsOutput).Encode(event); err != nil {
+		log.Debug("Failed to emit event: " + err.Error())
+	}
+}
+
+// emitArtifactEvents emits an event for every file in an artifact directory
+func emitArtifactEvents(artifactPath string) {
+	checksums, err := util.ReadChecksums(artifactPath)
+	if err != nil {
+		log.Warn("Warning: Failed to read artifact checksums: ", err)
+		return
+	}
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		event := buildEvent{Event: "artifact", Path: artifactPath + "/" + name, SHA256: checksums[name]}
+		if info, err := os.Stat(event.Path); err == nil {
+			event.Size = info.Size()
+		}
+		emitEvent(event)
+	}
+}
+
+// eventHook emits warnings and errors as events
+type eventHook struct {
+}
+
+// Fire emits the log message as a warning or error event
+func (hook *eventHook) Fire(entry *logrus.Entry) error {
+	event := buildEvent{Event: "warning", Message: entry.Message}
+	if entry.Level <= logrus.ErrorLevel {
+		event.Event = "error"
+	}
+	if currentStep != nil {
+		event.Step = currentStep.ID
+	}
+	emitEvent(event)
+	return nil
+}
+
+// Levels define on which log levels this hook would trigger
+func (hook *eventHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}
+}
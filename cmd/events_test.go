@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dids/clobber/util"
+)
+
+func TestRunSummary(t *testing.T) {
+	started := time.Date(2020, 11, 3, 14, 5, 9, 0, time.UTC)
+	record := &util.RunRecord{
+		ID:         util.NewRunID(started),
+		Started:    started,
+		Finished:   started.Add(90 * time.Second),
+		Outcome:    util.RunFailed,
+		FailedStep: "build-boot6",
+		Steps:      []util.StepInfo{{ID: "build-tools", Title: "Building base tools", Duration: 1500 * time.Millisecond}},
+	}
+	data, err := json.Marshal(buildEvent{Event: "summary", Summary: newRunSummary(record)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Like the other events, keys are camelCase and durations are in seconds
+	for _, expected := range []string{`"failedStep":"build-boot6"`, `"duration":90`, `"steps":[{"id":"build-tools","title":"Building base tools","duration":1.5}]`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in the summary event, got %s", expected, data)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dids/clobber/snake"
+	"github.com/Dids/clobber/util"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// snakeRenderer shows the build progress in the status row of the snake game,
+// falling back to plain lines if the game is exited before the build is done
+type snakeRenderer struct {
+	plainRenderer
+
+	config *snake.Config
+	game   *snake.Game
+
+	// Closed once the game has exited
+	done chan struct{}
+
+	// Set once the game shows the error that ended the build (by the logrus hook, so it's atomic)
+	failed int32
+
+	// Errors written to the terminal while the game is played
+	errors *heldWriter
+}
+
+// newSnakeRenderer loads the config of the game, so any mistakes in it are reported before building
+func newSnakeRenderer() *snakeRenderer {
+	config, err := snake.LoadConfig(util.GetSnakeConfigPath())
+	if err != nil {
+		log.Fatal("Error: Failed to load the snake config: ", err)
+	}
+	return &snakeRenderer{plainRenderer: plainRenderer{out: os.Stdout}, config: config, errors: &heldWriter{out: os.Stderr}}
+}
+
+// Banner doesn't show anything, as the game takes over the terminal
+func (renderer *snakeRenderer) Banner() {
+}
+
+// Start starts the game
+func (renderer *snakeRenderer) Start() {
+	if renderer.game != nil {
+		return
+	}
+	renderer.game = snake.NewGame(renderer.config)
+	if Autopilot {
+		renderer.game.EnableAutopilot()
+	}
+	renderer.done = make(chan struct{})
+	renderer.errors.hold()
+	go func() {
+		renderer.game.Start()
+		close(renderer.done)
+		renderer.errors.release()
+	}()
+}
+
+// StepStarted shows the step, along with its expected duration and the remaining time of the build
+func (renderer *snakeRenderer) StepStarted(step *buildStep) {
+	if !renderer.playing() {
+		renderer.plainRenderer.StepStarted(step)
+		return
+	}
+	status := snake.BuildStatus{Step: step.Title, Started: step.started, Remaining: -1}
+	if runRecord != nil {
+		status.Revision = runRecord.Describe
+		if len(status.Revision) == 0 {
+			status.Revision = runRecord.Revision
+		}
+	}
+	if expected, ok := buildEstimate.Step(step.ID); ok {
+		status.Expected = expected
+	}
+	stepsMutex.Lock()
+	finished := append([]util.StepInfo(nil), completedSteps...)
+	stepsMutex.Unlock()
+	if _, remaining, ok := buildEstimate.Progress(finished, step.ID, 0); ok {
+		status.Remaining = remaining
+	}
+	renderer.game.SetStatus(status)
+}
+
+// StepFinished only shows the step if the game has been exited, as the game shows the next step instead
+func (renderer *snakeRenderer) StepFinished(step *buildStep, duration time.Duration) {
+	if !renderer.playing() {
+		renderer.plainRenderer.StepFinished(step, duration)
+	}
+}
+
+// Info only shows the message if the game has been exited (it's still logged)
+func (renderer *snakeRenderer) Info(text string) {
+	if !renderer.playing() {
+		renderer.plainRenderer.Info(text)
+	}
+}
+
+// Warning only shows the warning if the game has been exited (it's still logged)
+func (renderer *snakeRenderer) Warning(text string) {
+	if !renderer.playing() {
+		renderer.plainRenderer.Warning(text)
+	}
+}
+
+// Stop shows the result in the game (unless it already shows the error that ended the build)
+// and waits for it to be exited, before showing the final message
+func (renderer *snakeRenderer) Stop(message string) {
+	if renderer.playing() {
+		if atomic.LoadInt32(&renderer.failed) == 0 {
+			// Without the party poppers, as the game can't handle wide characters
+			renderer.game.Finish(strings.Trim(message, " \n🎉"), true)
+		}
+		<-renderer.done
+	}
+	renderer.plainRenderer.Stop(message)
+}
+
+// errorOutput returns the writer for errors, which are printed once the game is exited
+func (renderer *snakeRenderer) errorOutput() io.Writer {
+	return renderer.errors
+}
+
+// Fire shows the error that ends the build in the game (or exits it if the user aborted the
+// build), which Stop then waits for, as logrus holds its lock while firing hooks
+func (renderer *snakeRenderer) Fire(entry *logrus.Entry) error {
+	if !renderer.playing() {
+		return nil
+	}
+	atomic.StoreInt32(&renderer.failed, 1)
+	if abortError() != nil {
+		renderer.game.Quit()
+	} else {
+		renderer.game.Finish(strings.SplitN(entry.Message, "\n", 2)[0], false)
+	}
+	return nil
+}
+
+// Levels define on which log levels this hook would trigger
+func (renderer *snakeRenderer) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel}
+}
+
+// playing returns true if the game has been started and not yet exited
+func (renderer *snakeRenderer) playing() bool {
+	if renderer.game == nil {
+		return false
+	}
+	select {
+	case <-renderer.done:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Dids/clobber/util"
+	logrus "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// HistoryCount is the number of runs to list
+var HistoryCount int
+
+// runRecord is the history record of the current build (nil if not recording)
+var runRecord *util.RunRecord
+
+// runAborted is set when the current build is aborted by the user (see abortBuild)
+var runAborted bool
+
+// historyCmd lists the most recent runs
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List previous builds",
+	Long: `List previous builds in the current workspace.
+
+Every build is recorded with its revision, toolchain, flags, step durations
+and outcome. Use 'clobber history show <id>' for the details of a single run,
+where <id> is a run ID (or a unique prefix of it), or "latest".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		records := readHistory()
+		if len(records) == 0 {
+			fmt.Println("No builds in " + util.GetHistoryPath())
+			return
+		}
+		if HistoryCount > 0 && len(records) > HistoryCount {
+			records = records[len(records)-HistoryCount:]
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(writer, "ID\tREVISION\tTOOLCHAIN\tDURATION\tOUTCOME")
+		for i := len(records) - 1; i >= 0; i-- {
+			record := records[i]
+			outcome := record.Outcome
+			if len(record.FailedStep) > 0 {
+				outcome += " (" + record.FailedStep + ")"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", record.ID, formatRevision(record), record.Toolchain, formatDuration(record.Duration()), outcome)
+		}
+		writer.Flush()
+	},
+}
+
+// historyShowCmd shows the step timings and error summary of a single run
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the details of a previous build",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		record, err := util.FindRunRecord(readHistory(), args[0])
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+
+		fmt.Println("ID:         " + record.ID)
+		fmt.Println("Workspace:  " + record.Workspace)
+		fmt.Println("Revision:   " + formatRevision(record))
+		if len(record.SHA) > 0 {
+			fmt.Println("SHA:        " + record.SHA)
+		}
+		fmt.Println("Toolchain:  " + record.Toolchain)
+		fmt.Println("Flags:      " + strings.Join(record.Flags, " "))
+		fmt.Println("Started:    " + record.Started.Format(time.RFC1123))
+		fmt.Println("Duration:   " + formatDuration(record.Duration()))
+		fmt.Println("Outcome:    " + record.Outcome)
+		fmt.Println("Log file:   " + record.LogFile)
+		if len(record.ArtifactPath) > 0 {
+			fmt.Println("Artifacts:  " + record.ArtifactPath)
+		}
+
+		if len(record.Steps) > 0 || len(record.FailedStep) > 0 {
+			fmt.Println()
+			writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(writer, "STEP\tDURATION\t")
+			for _, step := range record.Steps {
+				fmt.Fprintf(writer, "%s\t%s\t\n", step.ID, formatDuration(step.Duration))
+			}
+			if len(record.FailedStep) > 0 {
+				fmt.Fprintf(writer, "%s\t-\t%s\n", record.FailedStep, record.Outcome)
+			}
+			writer.Flush()
+		}
+
+		if len(record.Error) > 0 {
+			fmt.Println()
+			fmt.Println(record.Error)
+		}
+	},
+}
+
+// historyStatsCmd shows the success rate and mean build time per revision range
+var historyStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the success rate and mean build time per revision range",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		records := readHistory()
+		if len(records) == 0 {
+			fmt.Println("No builds in " + util.GetHistoryPath())
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(writer, "REVISION RANGE\tRUNS\tSUCCESS RATE\tMEAN BUILD TIME")
+		for _, stats := range util.ComputeRunStats(records) {
+			meanDuration := "-"
+			if stats.Successes > 0 {
+				meanDuration = formatDuration(stats.MeanDuration)
+			}
+			fmt.Fprintf(writer, "%s\t%d\t%.0f%%\t%s\n", stats.Range, stats.Runs, 100*float64(stats.Successes)/float64(stats.Runs), meanDuration)
+		}
+		writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyStatsCmd)
+	historyCmd.Flags().IntVarP(&HistoryCount, "count", "c", 20, "number of builds to list (0 lists all)")
+}
+
+// historyHook records the error (and the step it happened in) that ends a run
+type historyHook struct {
+}
+
+// Fire stores the first fatal error in the current run record
+func (hook *historyHook) Fire(entry *logrus.Entry) error {
+	if runRecord != nil && len(runRecord.Error) == 0 {
+		runRecord.SetError(entry.Message)
+		if currentStep != nil {
+			runRecord.FailedStep = currentStep.ID
+		}
+	}
+	return nil
+}
+
+// Levels define on which log levels this hook would trigger
+func (hook *historyHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel}
+}
+
+// startRunRecord starts recording the current build in the history,
+// which is saved as failed if the process exits with a fatal error
+func startRunRecord(started time.Time) {
+	runRecord = &util.RunRecord{
+		ID:        util.NewRunID(started),
+		Workspace: util.GetWorkspace(),
+		Started:   started,
+		Toolchain: Toolchain,
+		Flags:     os.Args[1:],
+		LogFile:   util.GetLogFilePath(),
+	}
+	if !util.IsExternalCloverPath() {
+		runRecord.Revision = Revision
+	}
+	log.AddHook(&historyHook{})
+	logrus.RegisterExitHandler(func() { finishRunRecord(util.RunFailed) })
+}
+
+// finishRunRecord saves the current build to the history
+func finishRunRecord(outcome string) {
+	if runRecord == nil {
+		return
+	}
+	if outcome == util.RunFailed && abortError() != nil {
+		outcome = util.RunAborted
+	}
+	runRecord.Outcome = outcome
+	runRecord.Finished = time.Now()
+	runRecord.Steps = completedSteps
+	emitEvent(buildEvent{Event: "summary", Summary: newRunSummary(runRecord)})
+	if err := util.AppendRunRecord(util.GetHistoryPath(), runRecord); err != nil {
+		log.Warn("Warning: Failed to save build history: ", err)
+	}
+	runRecord = nil
+}
+
+// readHistory returns all runs in the current workspace, or exits if the history can't be read
+func readHistory() []*util.RunRecord {
+	records, err := util.ReadRunRecords(util.GetHistoryPath())
+	if err != nil {
+		log.Fatal("Error: Failed to read build history: ", err)
+	}
+	return records
+}
+
+// formatRevision returns the best description of the revision a run built
+func formatRevision(record *util.RunRecord) string {
+	if len(record.Describe) > 0 {
+		return record.Describe
+	}
+	if len(record.Revision) > 0 {
+		return record.Revision
+	}
+	return "-"
+}
+
+// formatDuration returns a short duration (eg. "12m3s" or "<1s"), or "-" if it's unknown
+func formatDuration(duration time.Duration) string {
+	if duration <= 0 {
+		return "-"
+	}
+	if duration < time.Second {
+		return "<1s"
+	}
+	return duration.Round(time.Second).String()
+}
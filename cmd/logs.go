@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Dids/clobber/util"
+	"github.com/spf13/cobra"
+)
+
+// LogsStep shows the log of a single step instead of the whole run
+var LogsStep string
+
+// LogsFollow keeps showing new lines until the build is done
+var LogsFollow bool
+
+// LogsErrors only shows compiler errors and warnings
+var LogsErrors bool
+
+// runLogMaxAge is how long the log files of old runs are kept
+const runLogMaxAge = 90 * 24 * time.Hour
+
+// logConsole is where log messages are shown, besides the log file
+var logConsole io.Writer = os.Stdout
+
+// currentRunID is the ID of the run logging to its own log file (empty if there's none)
+var currentRunID string
+
+// stepLog is the log file of the current step (nil if there's none)
+var stepLog *os.File
+
+// logsCmd shows the log of a run or one of its steps
+var logsCmd = &cobra.Command{
+	Use:   "logs [run]",
+	Short: "Show the log of a build",
+	Long: `Show the log of a build (the latest one if no run is given), or a single
+step of it with --step (eg. 'clobber logs --step build-boot7').
+
+Runs are identified by their ID (see 'clobber history'), or a unique prefix of it.
+Use --errors to only show compiler errors and warnings, and --follow to keep
+showing new lines until the build is done.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runIDs, err := util.ListRunLogs()
+		if err != nil {
+			log.Fatal("Error: Failed to list logs: ", err)
+		}
+		runID := "latest"
+		if len(args) > 0 {
+			runID = args[0]
+		}
+		runID, err = findRunLog(runIDs, runID)
+		if err != nil {
+			log.Fatal("Error: ", err)
+		}
+
+		logPath := util.GetRunLogPath(runID)
+		if len(LogsStep) > 0 {
+			logPath = util.GetStepLogPath(runID, LogsStep)
+			if _, err := os.Stat(logPath); os.IsNotExist(err) {
+				stepIDs, _ := util.ListStepLogs(runID)
+				log.Fatal("Error: Run " + runID + " has no log for step '" + LogsStep + "' (available steps: " + strings.Join(stepIDs, ", ") + ")")
+			}
+		}
+
+		// Keep following until the run is in the history (or, if it ended before getting that far,
+		// until its process is gone)
+		done := func() bool {
+			if !LogsFollow {
+				return true
+			}
+			return isRunRecorded(runID) || !util.IsRunLogLocked(runID)
+		}
+		err = util.FollowFile(logPath, done, func(line string) {
+			if !LogsErrors || util.IsDiagnosticLine(line) {
+				fmt.Println(line)
+			}
+		})
+		if err != nil {
+			log.Fatal("Error: Failed to read log: ", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().StringVarP(&LogsStep, "step", "s", "", "show the log of a single step (eg. build-boot7)")
+	logsCmd.Flags().BoolVarP(&LogsFollow, "follow", "f", false, "keep showing new lines until the build is done")
+	logsCmd.Flags().BoolVarP(&LogsErrors, "errors", "e", false, "only show errors and warnings")
+}
+
+// findRunLog returns the run with the given ID (or a unique prefix of it),
+// or the most recent run if the ID is "latest"
+func findRunLog(runIDs []string, id string) (string, error) {
+	if len(runIDs) == 0 {
+		return "", fmt.Errorf("no run logs in %s", util.GetLogsPath())
+	}
+	if id == "latest" {
+		return runIDs[len(runIDs)-1], nil
+	}
+	var matches []string
+	for _, runID := range runIDs {
+		if runID == id {
+			return runID, nil
+		}
+		if strings.HasPrefix(runID, id) {
+			matches = append(matches, runID)
+		}
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no log for run '%s'", id)
+	} else if len(matches) > 1 {
+		return "", fmt.Errorf("run '%s' is ambiguous (%d matches)", id, len(matches))
+	}
+	return matches[0], nil
+}
+
+// isRunRecorded returns true if the run is in the history, which is only written once it's done
+func isRunRecorded(runID string) bool {
+	records, err := util.ReadRunRecords(util.GetHistoryPath())
+	if err != nil {
+		return false
+	}
+	for _, record := range records {
+		if record.ID == runID {
+			return true
+		}
+	}
+	return false
+}
+
+// startRunLog switches logging to the run's own log file, which
+// is also where the logs of its individual steps are stored
+func startRunLog(runID string) {
+	runLogFile, err := os.OpenFile(util.GetRunLogPath(runID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatal("Error: Failed to create log file: ", err)
+	}
+	if err := util.LockRunLog(runLogFile); err != nil {
+		log.Warn("Warning: Failed to lock log file: ", err)
+	}
+	currentRunID = runID
+	util.SetRunLog(runID)
+	log.SetOutput(io.MultiWriter(logConsole, runLogFile))
+	log.Debug("Logging to " + util.GetRunLogPath(runID))
+
+	if err := util.PruneRunLogs(runLogMaxAge); err != nil {
+		log.Warn("Warning: Failed to remove old logs: ", err)
+	}
+}
+
+// openStepLog creates the log file for a step of the current run
+func openStepLog(stepID string) {
+	if len(currentRunID) == 0 {
+		return
+	}
+	stepLogPath := util.GetStepLogPath(currentRunID, stepID)
+	if err := os.MkdirAll(util.GetLogsPath()+"/"+currentRunID, 0755); err != nil {
+		log.Warn("Warning: Failed to create step log: ", err)
+		return
+	}
+	file, err := os.OpenFile(stepLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Warn("Warning: Failed to create step log: ", err)
+		return
+	}
+	stepLog = file
+}
+
+// closeStepLog closes the log file of the current step
+func closeStepLog() {
+	if stepLog != nil {
+		stepLog.Close()
+		stepLog = nil
+	}
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	figure "github.com/common-nighthawk/go-figure"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ANSI colors used by the TTY renderer
+const (
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// progressRenderer shows the progress of a build to the user
+type progressRenderer interface {
+	// Banner shows the application name and version
+	Banner()
+
+	// Start starts showing progress
+	Start()
+
+	// StepStarted shows that a build step has started
+	StepStarted(step *buildStep)
+
+	// StepFinished shows that a build step has finished
+	StepFinished(step *buildStep, duration time.Duration)
+
+	// Info shows an informational message
+	Info(text string)
+
+	// Warning shows a warning
+	Warning(text string)
+
+	// Stop stops showing progress and shows the final message (if any)
+	Stop(message string)
+}
+
+// fullScreenRenderer is a renderer that takes over the terminal, so anything
+// else written to it (eg. the error that ends the build) has to wait until it's stopped
+type fullScreenRenderer interface {
+	progressRenderer
+
+	// errorOutput returns the writer for errors, which holds them back while the renderer is shown
+	errorOutput() io.Writer
+}
+
+// progress is the renderer used for showing the progress of the build
+var progress progressRenderer = &silentRenderer{}
+
+// newProgressRenderer returns the most suitable renderer for the output format and terminal
+func newProgressRenderer() progressRenderer {
+	switch {
+	case jsonOutput():
+		return &jsonRenderer{}
+	case TUI:
+		return newTUIRenderer(Verbose)
+	case Hiss:
+		return newSnakeRenderer()
+	case Quiet || Verbose:
+		// Verbose output shows the logs instead
+		return &silentRenderer{}
+	case isInteractive():
+		return &ttyRenderer{out: os.Stdout, color: useColor()}
+	default:
+		return &plainRenderer{out: os.Stdout}
+	}
+}
+
+// isInteractive returns true if stdout is a terminal and we're not running in CI
+func isInteractive() bool {
+	return terminal.IsTerminal(int(os.Stdout.Fd())) && len(os.Getenv("CI")) == 0
+}
+
+// useColor returns true unless colors are disabled with NO_COLOR (see https://no-color.org)
+func useColor() bool {
+	return len(os.Getenv("NO_COLOR")) == 0
+}
+
+// formatStepTiming returns the elapsed (and expected, if known) time of a step (eg. " [2m3s/~5m0s]")
+func formatStepTiming(step *buildStep, elapsed time.Duration) string {
+	if expected, ok := buildEstimate.Step(step.ID); ok {
+		return fmt.Sprintf(" [%s/~%s]", formatDuration(elapsed), formatDuration(expected))
+	}
+	return fmt.Sprintf(" [%s]", formatDuration(elapsed))
+}
+
+// heldWriter passes output through, except while it's held back (see fullScreenRenderer)
+type heldWriter struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	held   bool
+	buffer bytes.Buffer
+}
+
+// Write writes the output, or keeps it until it's released
+func (writer *heldWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	if writer.held {
+		return writer.buffer.Write(data)
+	}
+	return writer.out.Write(data)
+}
+
+// hold keeps any output from now on until it's released
+func (writer *heldWriter) hold() {
+	writer.mutex.Lock()
+	writer.held = true
+	writer.mutex.Unlock()
+}
+
+// release writes the output that was held back, passing through any output from now on
+func (writer *heldWriter) release() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.held = false
+	writer.out.Write(writer.buffer.Bytes())
+	writer.buffer.Reset()
+}
+
+// ttyRenderer shows an animated spinner with live progress on an interactive terminal
+type ttyRenderer struct {
+	out   io.Writer
+	color bool
+}
+
+// Banner shows the application logo
+func (renderer *ttyRenderer) Banner() {
+	logo := figure.NewFigure("CLOBBER", "puffy", true)
+	fmt.Fprint(renderer.out, logo.String())
+	fmt.Fprintln(renderer.out, "                                  v"+Version+" by @Dids")
+	fmt.Fprintln(renderer.out)
+}
+
+// Start starts the spinner
+func (renderer *ttyRenderer) Start() {
+	Spinner.PreUpdate = updateSpinnerProgress
+	Spinner.Start()
+}
+
+// StepStarted doesn't need to do anything, as the spinner shows the current step
+func (renderer *ttyRenderer) StepStarted(step *buildStep) {
+}
+
+// StepFinished replaces the spinner line with the finished step
+func (renderer *ttyRenderer) StepFinished(step *buildStep, duration time.Duration) {
+	renderer.printLine(renderer.colorize(colorGreen, "✔"), step.Title+formatStepTiming(step, duration))
+}
+
+// Info shows an informational message above the spinner
+func (renderer *ttyRenderer) Info(text string) {
+	renderer.printLine("→", text)
+}
+
+// Warning shows a warning above the spinner
+func (renderer *ttyRenderer) Warning(text string) {
+	renderer.printLine(renderer.colorize(colorYellow, "⚠"), text)
+}
+
+// Stop stops the spinner, replacing it with the final message
+func (renderer *ttyRenderer) Stop(message string) {
+	if !Spinner.Active() {
+		fmt.Fprint(renderer.out, message)
+		return
+	}
+	Spinner.FinalMSG = message
+	Spinner.Stop()
+}
+
+// printLine prints a line while the spinner is paused, so the two never end up on the same line
+func (renderer *ttyRenderer) printLine(symbol string, text string) {
+	Spinner.Lock()
+	defer Spinner.Unlock()
+	fmt.Fprintf(renderer.out, "\r\033[K%s %s\n", symbol, text)
+}
+
+// colorize wraps text in an ANSI color, unless colors are disabled
+func (renderer *ttyRenderer) colorize(color string, text string) string {
+	if !renderer.color {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// updateSpinnerProgress shows the elapsed and expected time of the current step,
+// along with the overall progress (called by the spinner before every update)
+func updateSpinnerProgress(s *spinner.Spinner) {
+	stepsMutex.Lock()
+	defer stepsMutex.Unlock()
+	if currentStep == nil {
+		s.Prefix = ""
+		return
+	}
+	elapsed := time.Since(currentStep.started)
+	text := currentStep.Title + formatStepTiming(currentStep, elapsed)
+	if percent, remaining, ok := buildEstimate.Progress(completedSteps, currentStep.ID, elapsed); ok {
+		text += fmt.Sprintf(" %.0f%%, %s", percent, formatRemainingTime(remaining))
+	}
+	s.Prefix = "◌ " + text + " "
+}
+
+// plainRenderer shows progress as plain lines, without any escape codes (eg. for CI logs)
+type plainRenderer struct {
+	out io.Writer
+}
+
+// Banner shows the application name and version
+func (renderer *plainRenderer) Banner() {
+	fmt.Fprintln(renderer.out, "Clobber v"+Version+" by @Dids")
+}
+
+// Start doesn't need to do anything
+func (renderer *plainRenderer) Start() {
+}
+
+// StepStarted shows the step, along with its expected duration (if known)
+func (renderer *plainRenderer) StepStarted(step *buildStep) {
+	if expected, ok := buildEstimate.Step(step.ID); ok {
+		fmt.Fprintf(renderer.out, "◌ %s (expected to take %s)\n", step.Title, formatDuration(expected))
+		return
+	}
+	fmt.Fprintf(renderer.out, "◌ %s\n", step.Title)
+}
+
+// StepFinished shows the step along with how long it took
+func (renderer *plainRenderer) StepFinished(step *buildStep, duration time.Duration) {
+	fmt.Fprintf(renderer.out, "✔ %s%s\n", step.Title, formatStepTiming(step, duration))
+}
+
+// Info shows an informational message
+func (renderer *plainRenderer) Info(text string) {
+	fmt.Fprintf(renderer.out, "→ %s\n", text)
+}
+
+// Warning shows a warning
+func (renderer *plainRenderer) Warning(text string) {
+	fmt.Fprintf(renderer.out, "⚠ %s\n", text)
+}
+
+// Stop shows the final message
+func (renderer *plainRenderer) Stop(message string) {
+	fmt.Fprint(renderer.out, message)
+}
+
+// silentRenderer doesn't show anything
+type silentRenderer struct {
+}
+
+// Banner doesn't show anything
+func (renderer *silentRenderer) Banner() {
+}
+
+// Start doesn't show anything
+func (renderer *silentRenderer) Start() {
+}
+
+// StepStarted doesn't show anything
+func (renderer *silentRenderer) StepStarted(step *buildStep) {
+}
+
+// StepFinished doesn't show anything
+func (renderer *silentRenderer) StepFinished(step *buildStep, duration time.Duration) {
+}
+
+// Info doesn't show anything
+func (renderer *silentRenderer) Info(text string) {
+}
+
+// Warning doesn't show anything
+func (renderer *silentRenderer) Warning(text string) {
+}
+
+// Stop doesn't show anything
+func (renderer *silentRenderer) Stop(message string) {
+}
+
+// jsonRenderer emits the progress as events (see OutputFormat)
+type jsonRenderer struct {
+	silentRenderer
+}
+
+// StepStarted emits a step_started event
+func (renderer *jsonRenderer) StepStarted(step *buildStep) {
+	emitEvent(buildEvent{Event: "step_started", Step: step.ID, Title: step.Title})
+}
+
+// StepFinished emits a step_finished event
+func (renderer *jsonRenderer) StepFinished(step *buildStep, duration time.Duration) {
+	emitEvent(buildEvent{Event: "step_finished", Step: step.ID, Title: step.Title, Duration: duration.Seconds()})
+}
+
+// Info emits an info event (warnings are emitted for all logged warnings by eventHook)
+func (renderer *jsonRenderer) Info(text string) {
+	emitEvent(buildEvent{Event: "info", Message: text})
+}
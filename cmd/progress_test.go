@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dids/clobber/util"
+)
+
+func TestNewProgressRenderer(t *testing.T) {
+	rootPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(rootPath)
+	defer os.Setenv("CLOBBER_HOME", os.Getenv("CLOBBER_HOME"))
+	os.Setenv("CLOBBER_HOME", rootPath)
+	defer func(format string, tui, hiss, quiet, verbose bool) {
+		OutputFormat, TUI, Hiss, Quiet, Verbose = format, tui, hiss, quiet, verbose
+	}(OutputFormat, TUI, Hiss, Quiet, Verbose)
+
+	// The JSON output wins over everything, then the dashboard, the game and the flags hiding the progress
+	tests := []struct {
+		format   string
+		tui      bool
+		hiss     bool
+		quiet    bool
+		verbose  bool
+		renderer string
+	}{
+		{jsonOutputFormat, true, true, true, true, "*cmd.jsonRenderer"},
+		{textOutputFormat, true, true, true, true, "*cmd.tuiRenderer"},
+		{textOutputFormat, false, true, true, true, "*cmd.snakeRenderer"},
+		{textOutputFormat, false, false, true, false, "*cmd.silentRenderer"},
+		{textOutputFormat, false, false, false, true, "*cmd.silentRenderer"},
+		// Tests don't run in a terminal
+		{textOutputFormat, false, false, false, false, "*cmd.plainRenderer"},
+	}
+	for _, test := range tests {
+		OutputFormat, TUI, Hiss, Quiet, Verbose = test.format, test.tui, test.hiss, test.quiet, test.verbose
+		renderer := newProgressRenderer()
+		if name := fmt.Sprintf("%T", renderer); name != test.renderer {
+			t.Errorf("%+v: expected %s, got %s", test, test.renderer, name)
+		}
+
+		// Before the game starts (and after it's exited), the game shows plain lines
+		if snakeRenderer, ok := renderer.(*snakeRenderer); ok && snakeRenderer.out != os.Stdout {
+			t.Errorf("Expected the game to fall back to stdout, got %v", snakeRenderer.out)
+		}
+	}
+}
+
+func TestPlainRenderer(t *testing.T) {
+	defer func(estimate *util.BuildEstimate) { buildEstimate = estimate }(buildEstimate)
+	buildEstimate = util.NewBuildEstimate([]*util.RunRecord{{
+		Outcome: util.RunSucceeded,
+		Steps:   []util.StepInfo{{ID: "build-tools", Duration: 2 * time.Minute}},
+	}}, "", nil)
+
+	output := &bytes.Buffer{}
+	renderer := &plainRenderer{out: output}
+	renderer.StepStarted(&buildStep{ID: "build-tools", Title: "Building base tools"})
+	renderer.StepFinished(&buildStep{ID: "build-tools", Title: "Building base tools"}, 90*time.Second)
+	renderer.StepStarted(&buildStep{ID: "build-iso", Title: "Building Clover ISO image"})
+	renderer.Info("Using toolchain XCODE8")
+	renderer.Warning("EFI tree is missing")
+	renderer.Stop("Done\n")
+
+	expected := []string{
+		"◌ Building base tools (expected to take 2m0s)",
+		"✔ Building base tools [1m30s/~2m0s]",
+		"◌ Building Clover ISO image",
+		"→ Using toolchain XCODE8",
+		"⚠ EFI tree is missing",
+		"Done",
+	}
+	if lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n"); strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), output.String())
+	}
+	if strings.Contains(output.String(), "\033") {
+		t.Errorf("Plain output should not contain escape codes: %q", output.String())
+	}
+}
+
+func TestJSONRenderer(t *testing.T) {
+	defer func(format string) { OutputFormat = format }(OutputFormat)
+	defer func() { eventsOutput = os.Stdout }()
+	output := &bytes.Buffer{}
+	eventsOutput = output
+	OutputFormat = jsonOutputFormat
+
+	renderer := &jsonRenderer{}
+	renderer.Banner()
+	renderer.StepStarted(&buildStep{ID: "build-tools", Title: "Building base tools"})
+	renderer.StepFinished(&buildStep{ID: "build-tools", Title: "Building base tools"}, 1500*time.Millisecond)
+	renderer.Info("Using toolchain XCODE8")
+	renderer.Stop("Done\n")
+
+	// Every line is an event, and nothing else is written
+	var events []buildEvent
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		event := buildEvent{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Invalid event %q: %s", line, err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %s", output.String())
+	}
+	if events[0].Event != "step_started" || events[0].Step != "build-tools" || events[0].Title != "Building base tools" {
+		t.Errorf("Invalid step_started event: %+v", events[0])
+	}
+	if events[1].Event != "step_finished" || events[1].Duration != 1.5 {
+		t.Errorf("Invalid step_finished event: %+v", events[1])
+	}
+	if events[2].Event != "info" || events[2].Message != "Using toolchain XCODE8" || events[2].Time.IsZero() {
+		t.Errorf("Invalid info event: %+v", events[2])
+	}
+}
+
+func TestTTYRendererColor(t *testing.T) {
+	for _, color := range []bool{true, false} {
+		output := &bytes.Buffer{}
+		renderer := &ttyRenderer{out: output, color: color}
+		renderer.Warning("EFI tree is missing")
+		if strings.Contains(output.String(), colorYellow) != color || !strings.Contains(output.String(), "EFI tree is missing\n") {
+			t.Errorf("Color %v: invalid warning %q", color, output.String())
+		}
+	}
+}
+
+func TestTTYRendererBanner(t *testing.T) {
+	output := &bytes.Buffer{}
+	renderer := &ttyRenderer{out: output}
+	renderer.Banner()
+	if lines := strings.Split(output.String(), "\n"); len(lines) < 4 || !strings.Contains(output.String(), "v"+Version+" by @Dids\n") {
+		t.Errorf("Invalid banner:\n%s", output.String())
+	}
+}
+
+func TestHeldWriter(t *testing.T) {
+	output := &bytes.Buffer{}
+	writer := &heldWriter{out: output}
+	fmt.Fprint(writer, "before ")
+	writer.hold()
+	fmt.Fprint(writer, "held ")
+	if output.String() != "before " {
+		t.Errorf("Expected output to be held back, got %q", output.String())
+	}
+	writer.release()
+	fmt.Fprint(writer, "after")
+	if output.String() != "before held after" {
+		t.Errorf("Expected the held output once released, got %q", output.String())
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Dids/clobber/util"
+	"github.com/spf13/cobra"
+)
+
+// RevisionsCount is the amount of tags and releases to list
+var RevisionsCount int
+
+// revisionsCmd lists recent upstream Clover tags and releases
+var revisionsCmd = &cobra.Command{
+	Use:   "revisions",
+	Short: "List recent upstream Clover tags and releases",
+	Long: `List recent upstream Clover tags and releases, which can be
+used with --revision (as can branch names, commit SHAs and the
+"` + util.LatestReleaseRevision + `" and "` + util.LatestTagRevision + `" aliases).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+		// List the releases from GitHub
+		releases, releasesErr := util.GetGitHubReleases(util.CloverRepository, RevisionsCount)
+		if releasesErr != nil {
+			log.Warn("Warning: Failed to list Clover releases: ", releasesErr)
+			fmt.Fprintln(writer, "Failed to list releases:", releasesErr)
+		} else {
+			fmt.Fprintln(writer, "RELEASE\tPUBLISHED\tNAME")
+			for _, release := range releases {
+				name := release.Name
+				if release.Prerelease {
+					name += " (prerelease)"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\n", release.TagName, release.PublishedAt.Format("2006-01-02"), name)
+			}
+		}
+		fmt.Fprintln(writer)
+
+		// List the tags from the local Clover checkout, updating them first
+		setupSourceDir()
+		if _, err := os.Stat(util.GetCloverPath() + "/.git"); os.IsNotExist(err) {
+			fmt.Fprintln(writer, "Clover has not been downloaded yet, run 'clobber --update-only' to list tags")
+		} else {
+			if !util.IsExternalCloverPath() {
+				if err := runCommand("git fetch --tags --force --prune origin", util.GetCloverPath(), nil); err != nil {
+					log.Warn("Warning: Failed to update Clover tags, listing local tags only")
+				}
+			}
+			tags, tagsErr := util.GetTags(util.GetCloverPath(), RevisionsCount)
+			if tagsErr != nil {
+				log.Fatal("Error: Failed to list Clover tags: ", tagsErr)
+			}
+			fmt.Fprintln(writer, "TAG\tCREATED")
+			for _, tag := range tags {
+				fmt.Fprintf(writer, "%s\t%s\n", tag.Name, tag.Date.Format("2006-01-02"))
+			}
+		}
+
+		writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(revisionsCmd)
+	revisionsCmd.Flags().IntVarP(&RevisionsCount, "count", "c", 10, "amount of tags and releases to list")
+}
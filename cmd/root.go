@@ -1,21 +1,23 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Dids/clobber/patches"
-	"github.com/Dids/clobber/snake"
 	"github.com/Dids/clobber/util"
-	figure "github.com/common-nighthawk/go-figure"
 	"github.com/gobuffalo/packr/v2"
 	"github.com/mholt/archiver"
 	logrus "github.com/sirupsen/logrus"
@@ -24,6 +26,7 @@ import (
 
 	"github.com/briandowns/spinner"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // Version is set in main.go and is overridable when building
@@ -53,18 +56,49 @@ var NoClean bool
 // Toolchain to use when building Clover
 var Toolchain string
 
+// Workspace is the name of the workspace to use
+var Workspace string
+
+// Output is the directory for build artifacts (defaults to the workspace artifacts)
+var Output string
+
+// Wait for other runs using the same workspace to finish, instead of failing
+var Wait bool
+
+// CleanEnv only passes allowlisted host environment variables to the build
+var CleanEnv bool
+
+// SourceDir is an existing Clover checkout to build in place (optional)
+var SourceDir string
+
 // Spinner is the CLI spinner/activity indicator
 var Spinner = spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 
 // Hiss hiss, said the snake
 var Hiss bool
 
+// Autopilot lets the snake play itself
+var Autopilot bool
+
 // Controls whether to patch buildpkg.sh or not
 var patchBuildPkg = true
 
 // Controls whether to patch ebuild.sh or not
 var patchEbuild = false // NOTE: This is no longer necessary
 
+// Local changes in an external Clover checkout (see SourceDir)
+var localChanges []string
+
+// Information about the current build, collected when patching the installer
+var buildInfo *util.BuildInfo
+
+// Patches applied to Clover and its installer during the current build
+var appliedPatches []string
+
+// Original contents of files patched in an external Clover checkout,
+// which are restored once the build is done
+var preservedFiles = make(map[string][]byte)
+
 // Create a new logger
 var log = logrus.New()
 
@@ -98,9 +132,14 @@ type ErrorWriterHook struct {
 	LogLevels []logrus.Level
 }
 
-// Fire formats the error log and writes it to the output
+// Fire formats the error log and writes it to the output, pointing to the log
+// of the run for the details (other commands don't log more than the error itself)
 func (hook *ErrorWriterHook) Fire(entry *logrus.Entry) error {
-	_, err := hook.Writer.Write([]byte("\n\n" + entry.Message + "\n\nSee the log file for more details:\n" + util.GetLogFilePath() + "\n"))
+	message := "\n\n" + entry.Message + "\n"
+	if len(currentRunID) > 0 {
+		message += "\nSee the log file for more details:\n" + util.GetLogFilePath() + "\n"
+	}
+	_, err := hook.Writer.Write([]byte(message))
 	return err
 }
 
@@ -117,28 +156,34 @@ var rootCmd = &cobra.Command{
 				 Built by @Dids with tons of love, sweat and tears.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Setup graceful shutdown support
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGINT)
 		go func() {
 			<-c
-			log.Fatal("CTRL-C detected, aborting..")
+			abortBuild("CTRL-C detected, aborting..")
 		}()
 
-		// FIXME: Integrate this with the building process
-		if Hiss {
-			game := snake.NewGame()
-			go game.Start()
-		}
-
 		// Measure execution time
 		executionStartTime := time.Now()
 
-		// Print banner if not playing a game
-		if !Hiss {
-			logo := figure.NewFigure("CLOBBER", "puffy", true)
-			logo.Print()
-			fmt.Println("                                  v" + Version + " by @Dids")
-			fmt.Println()
+		// Path to the artifacts of this build (if any)
+		artifactPath := ""
+
+		// Print banner (unless playing a game or running non-interactively)
+		progress.Banner()
+
+		// Log this build to its own log file, before anything can fail
+		startRunLog(util.NewRunID(executionStartTime))
+
+		// The game would garble the events (or fight over the terminal with the dashboard)
+		if Hiss && TUI {
+			log.Fatal("Error: Cannot use --hiss and --tui simultaneously")
+		}
+		if Hiss && jsonOutput() {
+			log.Fatal("Error: Cannot use --hiss with --output-format " + jsonOutputFormat)
+		}
+		if Autopilot && !Hiss {
+			log.Fatal("Error: Cannot use --autopilot without --hiss")
 		}
 
 		// Don't allow a mixture of --build-only, --update-only and --installer-only to be used simultaneously
@@ -155,31 +200,70 @@ var rootCmd = &cobra.Command{
 			log.Fatal("Error: Cannot use --build-only, --update-only and --installer-only simultaneously")
 		}
 
-		// Start the spinner
-		if !Verbose && !Quiet {
-			Spinner.Start()
+		// Make sure nothing else is using the workspace while we're building
+		workspaceLock := lockWorkspace()
+		defer workspaceLock.Release()
+
+		// Use an existing Clover checkout instead of the managed one
+		if setupSourceDir() {
+			// Restore any files we patch, so the checkout is left as we found it
+			logrus.RegisterExitHandler(restorePreservedFiles)
+			defer restorePreservedFiles()
 		}
 
+		// Record this build in the history
+		startRunRecord(executionStartTime)
+
+		// Show the expected progress, based on previous builds
+		startProgressEstimate()
+
+		// Prepare the environment for all build commands
+		buildEnv := util.NewBuildEnv(Toolchain, CleanEnv)
+		log.Debug("Build environment:\n" + buildEnv.String())
+
+		// Start showing progress
+		pipeline = buildPipeline()
+		progress.Start()
+		logrus.RegisterExitHandler(func() { progress.Stop("") })
+
+		// Keep the workspace locked until the handlers above are done with it
+		logrus.RegisterExitHandler(func() { workspaceLock.Release() })
+
 		log.Debug("Target Clover revision:", Revision)
 		if args != nil && len(args) > 0 {
 			log.Debug("Building with arguments:", args)
 		}
 
 		// Make sure that the correct directory structure exists
-		log.Debug("Verifying folder structure..")
-		Spinner.Prefix = formatSpinnerText("Verifying folder structure", false)
+		beginStep("verify-folders", "Verifying folder structure")
 		time.Sleep(100 * time.Millisecond)
 		mkdirErr := os.MkdirAll(util.GetSourcePath(), 0755)
 		if mkdirErr != nil {
 			log.Fatal("Error: MkdirAll failed with error: ", mkdirErr)
 		}
-		Spinner.Prefix = formatSpinnerText("Verifying folder structure", true)
+		endStep()
 
 		// Remove any old edk2 installations
 		os.RemoveAll(util.GetSourcePath() + "/edk2")
 
+		// Never touch an external checkout with git, only warn about local changes
+		if util.IsExternalCloverPath() {
+			log.Debug("Using external Clover checkout at " + util.GetCloverPath())
+			if cmd.Flags().Changed("revision") {
+				printWarning("Ignoring --revision, as --source-dir is built as-is")
+			}
+			modifiedFiles, modifiedFilesErr := util.GetModifiedFiles(util.GetCloverPath())
+			if modifiedFilesErr != nil {
+				printWarning("Unable to detect local changes in " + util.GetCloverPath() + " (not a git checkout?)")
+			} else if len(modifiedFiles) > 0 {
+				localChanges = modifiedFiles
+				printWarning(fmt.Sprintf("Building a dirty tree (%d modified files in %s)", len(modifiedFiles), util.GetCloverPath()))
+				log.Debug("Local changes:\n" + strings.Join(modifiedFiles, "\n"))
+			}
+		}
+
 		// Download Clover
-		if _, err := os.Stat(util.GetCloverPath() + "/.git"); os.IsNotExist(err) {
+		if _, err := os.Stat(util.GetCloverPath() + "/.git"); os.IsNotExist(err) && !util.IsExternalCloverPath() {
 			// If Clover is missing and we're only supposed to build, we can't continue any further
 			if BuildOnly {
 				log.Fatal("Error: Clover is missing and using --build-only, cannot continue")
@@ -187,191 +271,110 @@ var rootCmd = &cobra.Command{
 			if InstallerOnly {
 				log.Fatal("Error: Clover is missing and using --installer-only, cannot continue")
 			}
-			log.Debug("Clover is missing, downloading..")
-			Spinner.Prefix = formatSpinnerText("Downloading Clover", false)
-			if err := runCommand("git clone -b "+Revision+" https://github.com/CloverHackyColor/CloverBootloader Clover", util.GetSourcePath()); err != nil {
+			beginStep("download", "Downloading Clover")
+			if err := runCommand("git clone "+util.CloverRepositoryURL+" Clover", util.GetSourcePath(), nil); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
-			Spinner.Prefix = formatSpinnerText("Downloading Clover", true)
+			endStep()
 		}
 
 		// Update Clover
-		if !BuildOnly && !InstallerOnly {
-			log.Debug("Verifying Clover is up to date..")
-			Spinner.Prefix = formatSpinnerText("Verifying Clover is up to date", false)
+		if !BuildOnly && !InstallerOnly && !util.IsExternalCloverPath() {
+			beginStep("update", "Verifying Clover is up to date")
 			// Disable cleaning up of extra files if the NoClean flag is set
 			if !NoClean {
-				if err := runCommand("git reset --hard", util.GetCloverPath()); err != nil {
+				if err := runCommand("git reset --hard", util.GetCloverPath(), nil); err != nil {
 					log.Fatal("Error: Failure detected, aborting\n", err)
 				}
-				if err := runCommand("git clean -fdx", util.GetCloverPath()); err != nil {
+				if err := runCommand("git clean -fdx", util.GetCloverPath(), nil); err != nil {
 					log.Fatal("Error: Failure detected, aborting\n", err)
 				}
 			}
-			if err := runCommand("git checkout "+Revision, util.GetCloverPath()); err != nil {
+			if err := runCommand("git fetch --tags --force --prune origin", util.GetCloverPath(), nil); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
-			Spinner.Prefix = formatSpinnerText("Verifying Clover is up to date", true)
-		}
-
-		if !UpdateOnly && !InstallerOnly {
-			// Override HOME environment variable (use chroot-like logic for the build process)
-			log.Debug("Overriding HOME..")
-			os.Setenv("HOME", util.GetClobberPath())
-
-			// Override TOOLCHAIR_DIR environment variable
-			log.Debug("Overriding TOOLCHAIN_DIR..")
-			os.Setenv("TOOLCHAIN_DIR", util.GetSourcePath()+"/opt/local")
-
-			// Override WORKSPACE environment variable
-			log.Debug("Overriding WORKSPACE..")
-			os.Setenv("WORKSPACE", util.GetCloverPath())
-
-			// Build base tools
-			log.Debug("Building base tools..")
-			Spinner.Prefix = formatSpinnerText("Building base tools", false)
-			if err := runCommand("make -C BaseTools/Source/C", util.GetCloverPath()); err != nil {
-				if err := runCommand("make clean -C BaseTools/Source/C", util.GetCloverPath()); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				if err := runCommand("make -C BaseTools/Source/C", util.GetCloverPath()); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
+			revisionSHA, resolveErr := util.ResolveRevision(util.GetCloverPath(), Revision)
+			if resolveErr != nil {
+				log.Fatal("Error: ", resolveErr)
 			}
-			Spinner.Prefix = formatSpinnerText("Building base tools", true)
-
-			// Setup EDK
-			log.Debug("Setting up EDK..")
-			Spinner.Prefix = formatSpinnerText("Setting up EDK", false)
-			if err := runCommand("source ./edksetup.sh BaseTools", util.GetCloverPath()); err != nil {
+			log.Debug("Resolved revision " + Revision + " to " + revisionSHA)
+			if err := runCommand("git checkout --detach "+revisionSHA, util.GetCloverPath(), nil); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
-			Spinner.Prefix = formatSpinnerText("Setting up EDK", true)
+			endStep()
+		}
 
-			// Build gettext, mtoc and nasm (if necessary)
-			if _, err := os.Stat(util.GetSourcePath() + "/opt/local/bin/gettext"); os.IsNotExist(err) {
-				log.Debug("Linking gettext..")
-				Spinner.Prefix = formatSpinnerText("Linking gettext", false)
-				if err := runCommand("brew link gettext --force --overwrite", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				defer runCommand("brew unlink gettext", "")
-				if err := runCommand("mkdir -p "+util.GetSourcePath()+"/opt/local/bin", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				if err := runCommand("ln -sf /usr/local/bin/gettext "+util.GetSourcePath()+"/opt/local/bin/gettext", ""); err != nil {
-					// if err := runCommand("ln -sf $(which gettext) "+util.GetSourcePath()+"/opt/local/bin/gettext", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				Spinner.Prefix = formatSpinnerText("Linking gettext", true)
-			}
-			if _, err := os.Stat(util.GetSourcePath() + "/opt/local/bin/mtoc.NEW"); os.IsNotExist(err) {
-				log.Debug("Building mtoc..")
-				Spinner.Prefix = formatSpinnerText("Building mtoc", false)
-				if err := runCommand(util.GetCloverPath()+"/buildmtoc.sh", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				Spinner.Prefix = formatSpinnerText("Building mtoc", true)
-			}
-			if _, err := os.Stat(util.GetSourcePath() + "/opt/local/bin/nasm"); os.IsNotExist(err) {
-				log.Debug("Linking nasm..")
-				Spinner.Prefix = formatSpinnerText("Linking nasm", false)
-				// TODO: This could be done better, checking if linking/unlinking is even necessary
-				if err := runCommand("brew link nasm --force --overwrite", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				defer runCommand("brew unlink nasm", "")
-				if err := runCommand("mkdir -p "+util.GetSourcePath()+"/opt/local/bin", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				if err := runCommand("ln -sf /usr/local/bin/nasm "+util.GetSourcePath()+"/opt/local/bin/nasm", ""); err != nil {
-					// if err := runCommand("ln -sf $(which nasm) "+util.GetSourcePath()+"/opt/local/bin/nasm", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				Spinner.Prefix = formatSpinnerText("Linking nasm", true)
+		// Show exactly which Clover revision is going to be built
+		if _, err := os.Stat(util.GetCloverPath() + "/.git"); err == nil {
+			if revisionSHA, revisionDescribe, describeErr := util.DescribeRevision(util.GetCloverPath(), "HEAD"); describeErr != nil {
+				printWarning("Unable to describe the Clover revision: " + describeErr.Error())
+			} else {
+				printInfo("Clover revision " + revisionDescribe + " (" + revisionSHA + ")")
+				runRecord.Describe = revisionDescribe
+				runRecord.SHA = revisionSHA
 			}
+		}
 
-			// FIXME: GCC building doesn't work yet
-			// Build with GCC instead of XCODE
-			if Toolchain != "XCODE8" {
-				if err := runCommand("mkdir -p "+util.GetSourcePath()+"/opt/local/cross/bin", ""); err != nil {
-					log.Fatal("Error: Failure detected, aborting\n", err)
-				}
-				if _, err := os.Stat(util.GetSourcePath() + "/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc"); os.IsNotExist(err) {
-					log.Debug("Linking gcc..")
-					Spinner.Prefix = formatSpinnerText("Linking gcc", false)
-					if err := runCommand("ln -sf /usr/local/bin/gcc-8 "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc", ""); err != nil {
-						// if err := runCommand("ln -sf $(which gcc) "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc", ""); err != nil {
-						log.Fatal("Error: Failure detected, aborting\n", err)
-					}
-					Spinner.Prefix = formatSpinnerText("Linking gcc", true)
-				}
-				if _, err := os.Stat(util.GetSourcePath() + "/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc-ar"); os.IsNotExist(err) {
-					log.Debug("Linking gcc-ar..")
-					Spinner.Prefix = formatSpinnerText("Linking gcc-ar", false)
-					if err := runCommand("ln -sf /usr/local/bin/gcc-ar-8 "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc-ar", ""); err != nil {
-						// if err := runCommand("ln -sf $(which gcc) "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc", ""); err != nil {
-						log.Fatal("Error: Failure detected, aborting\n", err)
-					}
-					Spinner.Prefix = formatSpinnerText("Linking gcc-ar", true)
-				}
-
-				// TODO: Add "x86_64-clover-linux-gnu-gcc" to PATH
-				os.Setenv("PATH", os.Getenv("PATH")+":"+util.GetSourcePath()+"/opt/local/cross/bin")
-				log.Info("Path:", os.Getenv("PATH"))
-
-				// FIXME: ccache: error: Could not find compiler "x86_64-clover-linux-gnu-gcc" in PATH
-				os.Setenv("GCC53_BIN", "gcc")
-			}
+		if !UpdateOnly && !InstallerOnly {
+			// Build base tools and prepare the toolchain and dependencies
+			cleanupBuild := prepareBuild(buildEnv)
+			defer cleanupBuild()
 
 			// Patch Clover.dsc (eg. skip building ApfsDriverLoader)
-			log.Debug("Patching Clover..")
-			Spinner.Prefix = formatSpinnerText("Patching Clover", false)
-			if err := runCommand("sed -i '' -e 's/^[^#]*ApfsDriverLoader/#&/' Clover.dsc", util.GetCloverPath()); err != nil {
+			beginStep("patch", "Patching Clover")
+			preserveFile(util.GetCloverPath() + "/Clover.dsc")
+			preserveFile(util.GetCloverPath() + "/vers.txt")
+			preserveFile(util.GetCloverPath() + "/ebuild.sh")
+			if err := runCommand("sed -i '' -e 's/^[^#]*ApfsDriverLoader/#&/' Clover.dsc", util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
-			if err := runCommand("sed -i '' -e 's/^[^#]*AptioMemoryFix/#&/' Clover.dsc", util.GetCloverPath()); err != nil {
+			if err := runCommand("sed -i '' -e 's/^[^#]*AptioMemoryFix/#&/' Clover.dsc", util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
-			if err := runCommand("sed -i '' -e 's/^[^#]*AptioInputFix/#&/' Clover.dsc", util.GetCloverPath()); err != nil {
+			if err := runCommand("sed -i '' -e 's/^[^#]*AptioInputFix/#&/' Clover.dsc", util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
+			appliedPatches = append(appliedPatches, "Clover.dsc (skip ApfsDriverLoader, AptioMemoryFix and AptioInputFix)")
 			// Patch vers.txt (current version is statically embedded for some dumb reason)
-			if err := runCommand("git describe --tags | tr -d '\n' > vers.txt", util.GetCloverPath()); err != nil {
+			if err := runCommand("git describe --tags | tr -d '\n' > vers.txt", util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
+			appliedPatches = append(appliedPatches, "vers.txt (git describe)")
 			// NOTE: This is no longer necessary
 			// Patch old vers.txt logic back in to ebuild.sh
 			if patchEbuild {
 				if err := patches.Patch(packedPatches, "ebuild", util.GetCloverPath()+"/ebuild.sh"); err != nil {
 					log.Fatal("Error: Failure detected, aborting\n", err)
 				}
+				appliedPatches = append(appliedPatches, "ebuild.sh (ebuild.patch)")
 			}
-			Spinner.Prefix = formatSpinnerText("Patching Clover", true)
+			endStep()
 
 			// Build Clover (clean & build, with extras like ApfsDriverLoader checked out and compiled)
-			log.Debug("Building Clover..")
-			Spinner.Prefix = formatSpinnerText("Building Clover", false)
+			beginStep("build-clean", "Cleaning Clover")
 			// TODO: Shouldn't this technically be ignored when using --no-clean?
-			if err := runCommand("source edksetup.sh BaseTools; ./ebuild.sh -cleanall -t "+Toolchain+" || true", util.GetCloverPath()); err != nil {
+			if err := runCommand("source edksetup.sh BaseTools; ./ebuild.sh -cleanall -t "+Toolchain+" || true", util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
+			endStep()
 			// 64-bit (boot6, default)
-			if err := runCommand("source edksetup.sh BaseTools; ./ebuild.sh -fr -D NO_GRUB_DRIVERS_EMBEDDED -t "+Toolchain, util.GetCloverPath()); err != nil {
+			beginStep("build-boot6", "Building Clover (boot6)")
+			if err := runCommand("source edksetup.sh BaseTools; ./ebuild.sh -fr -D NO_GRUB_DRIVERS_EMBEDDED -t "+Toolchain, util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
+			endStep()
 			// 64-bit (boot7, MCP/BiosBlockIO)
-			if err := runCommand("source edksetup.sh BaseTools; ./ebuild.sh -fr --x64-mcp --no-usb -D NO_GRUB_DRIVERS_EMBEDDED -t "+Toolchain, util.GetCloverPath()); err != nil {
+			beginStep("build-boot7", "Building Clover (boot7)")
+			if err := runCommand("source edksetup.sh BaseTools; ./ebuild.sh -fr --x64-mcp --no-usb -D NO_GRUB_DRIVERS_EMBEDDED -t "+Toolchain, util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
-			Spinner.Prefix = formatSpinnerText("Building Clover", true)
+			endStep()
 		}
 
 		// Handle special cases when using BuildOnly/UpdateOnly
 		if !BuildOnly && !InstallerOnly {
 			// Download and install extra EFI drivers
-			log.Debug("Updating extra EFI drivers..")
-			Spinner.Prefix = formatSpinnerText("Updating extra EFI drivers", false)
+			beginStep("drivers", "Updating extra EFI drivers")
 
 			// Make sure the driver paths exist (especially important when update only and on a clean install)
 			os.MkdirAll(util.GetCloverPath()+"/CloverPackage/CloverV2/EFI/CLOVER/drivers/UEFI", 0700)
@@ -473,21 +476,32 @@ var rootCmd = &cobra.Command{
 			// 	log.Fatal("Error: Failed to update extra EFI drivers (copy FwRuntimeServices.efi): ", err)
 			// }
 
-			Spinner.Prefix = formatSpinnerText("Updating extra EFI drivers", true)
+			endStep()
 		}
 
 		if !UpdateOnly {
 			// Update the status, since this is a multi-step process anyway (and because our spinner freaks out otherwise)
-			log.Debug("Patching Clover installer..")
-			Spinner.Prefix = formatSpinnerText("Patching Clover installer", false)
-
-			// Log important version information
-			log.Debug("Listing environment version information:\n" + util.GetVersionDump())
+			beginStep("patch-installer", "Patching Clover installer")
+
+			// Collect and log important version information
+			buildInfo = util.CollectBuildInfo(Toolchain)
+			buildInfo.ClobberVersion = Version
+			buildInfo.Started = executionStartTime
+			buildInfo.Flags = os.Args[1:]
+			buildInfo.Clover.LocalChanges = localChanges
+			if !util.IsExternalCloverPath() {
+				buildInfo.Clover.Revision = Revision
+			}
+			for _, probeErr := range buildInfo.Errors {
+				log.Warn("Warning: Failed to collect build information: " + probeErr)
+			}
+			log.Debug("Listing environment version information:\n" + strings.Join(buildInfo.Summary(), "\n"))
 
 			// Modify credits to differentiate between "official" and custom builds
 			log.Debug("Updating package credits..")
 			additionalCredits := "Custom package by Dids."
 			creditsFilePath := util.GetCloverPath() + "/CloverPackage/CREDITS"
+			preserveFile(creditsFilePath)
 			fileBuffer, fileReadErr := ioutil.ReadFile(creditsFilePath)
 			if fileReadErr != nil {
 				log.Fatal("Error: Failed to update package credits: ", fileReadErr)
@@ -499,17 +513,25 @@ var rootCmd = &cobra.Command{
 					log.Fatal("Error: Failed to update package credits: ", strReplaceErr)
 				}
 			}
+			appliedPatches = append(appliedPatches, "CREDITS (custom package)")
 
 			// Modify the installer package description to contain all important environment information
 			log.Debug("Updating package description..")
 			additionalDescription := "<p><b>Dids's build details:</b></p>\n"
 			additionalDescription += "<ul>\n"
-			versionDump := util.GetVersionDump()
-			for _, line := range strings.Split(strings.TrimSuffix(versionDump, "\n"), "\n") {
-				additionalDescription += "<li>" + line + "</li>\n"
+			for _, line := range buildInfo.Summary() {
+				additionalDescription += "<li>" + html.EscapeString(line) + "</li>\n"
+			}
+			if len(buildInfo.Clover.LocalChanges) > 0 {
+				additionalDescription += "<li>Local changes:\n<ul>\n"
+				for _, modifiedFile := range buildInfo.Clover.LocalChanges {
+					additionalDescription += "<li>" + html.EscapeString(modifiedFile) + "</li>\n"
+				}
+				additionalDescription += "</ul>\n</li>\n"
 			}
 			additionalDescription += "</ul>\n"
 			descriptionFilePath := util.GetCloverPath() + "/CloverPackage/package/Resources/templates/Description.html"
+			preserveFile(descriptionFilePath)
 			descriptionFileBuffer, descriptionFileReadErr := ioutil.ReadFile(descriptionFilePath)
 			if descriptionFileReadErr != nil {
 				log.Fatal("Error: Failed to update package description: ", descriptionFileReadErr)
@@ -521,12 +543,16 @@ var rootCmd = &cobra.Command{
 					log.Fatal("Error: Failed to update package description: ", strReplaceErr)
 				}
 			}
+			appliedPatches = append(appliedPatches, "Description.html (build details)")
 
 			// Patch the Clover installer package
+			preserveFile(util.GetCloverPath() + "/CloverPackage/package/buildpkg.sh")
+			preserveFile(util.GetCloverPath() + "/CloverPackage/package/Resources/background.tiff")
 			if patchBuildPkg {
 				if patchErr := patches.Patch(packedPatches, "buildpkg", util.GetCloverPath()+"/CloverPackage/package/buildpkg.sh"); patchErr != nil {
 					log.Fatal("Error: Failed to patch Clover installer (patch buildpkg.sh): ", patchErr)
 				}
+				appliedPatches = append(appliedPatches, "buildpkg.sh (buildpkg.patch)")
 			}
 			// Load the installer image asset
 			backgroundPatch, backgroundPatchErr := packedAssets.Find("background.tiff")
@@ -537,6 +563,7 @@ var rootCmd = &cobra.Command{
 			if writeErr := ioutil.WriteFile(util.GetCloverPath()+"/CloverPackage/package/Resources/background.tiff", backgroundPatch, 0644); writeErr != nil {
 				log.Fatal("Error: Failed to patch Clover installer (replace background.tiff): ", writeErr)
 			}
+			appliedPatches = append(appliedPatches, "background.tiff (installer background)")
 			// Load the compressed Metal theme
 			metalTheme, metalThemeErr := packedAssets.Find("metal_theme.tar.gz")
 			if metalThemeErr != nil {
@@ -560,41 +587,66 @@ var rootCmd = &cobra.Command{
 			if unarchiveErr := archiver.Unarchive(metalThemeTemp.Name(), util.GetCloverPath()+"/CloverPackage/CloverV2/themespkg"); unarchiveErr != nil {
 				log.Fatal("Error: Failed to patch Clover installer (extract metal_theme.tar.gz): ", unarchiveErr)
 			}
-			Spinner.Prefix = formatSpinnerText("Patching Clover installer", true)
+			appliedPatches = append(appliedPatches, "themespkg (Metal theme)")
+			endStep()
 
 			// Build the Clover installer package
-			log.Debug("Building Clover installer..")
-			Spinner.Prefix = formatSpinnerText("Building Clover installer", false)
-			if err := runCommand("./CloverPackage/makepkg", util.GetCloverPath()); err != nil {
+			beginStep("build-installer", "Building Clover installer")
+			if err := runCommand("./CloverPackage/makepkg", util.GetCloverPath(), buildEnv); err != nil {
 				log.Fatal("Error: Failure detected, aborting\n", err)
 			}
-			Spinner.Prefix = formatSpinnerText("Building Clover installer", true)
+			endStep()
 
 			if !InstallerOnly {
 				// Build the Clover ISO image
-				log.Debug("Building Clover ISO image..")
-				Spinner.Prefix = formatSpinnerText("Building Clover ISO image", false)
+				beginStep("build-iso", "Building Clover ISO image")
 				// if err := runCommand("./CloverPackage/makeiso", util.GetCloverPath()); err != nil {
-				if err := runCommand("make iso", util.GetCloverPath()+"/CloverPackage"); err != nil {
+				if err := runCommand("make iso", util.GetCloverPath()+"/CloverPackage", buildEnv); err != nil {
 					log.Fatal("Error: Failure detected, aborting\n", err)
 				}
-				Spinner.Prefix = formatSpinnerText("Building Clover ISO image", true)
+				endStep()
+			}
+
+			// Collect the installer, ISO and EFI tree into a versioned output directory
+			beginStep("collect", "Collecting artifacts")
+			artifactPath = collectArtifacts(buildInfo)
+			endStep()
+
+			// Write the build manifest next to the installer package and the artifacts
+			buildInfo.Patches = appliedPatches
+			buildInfo.Steps = completedSteps
+			buildInfo.Finished = time.Now()
+			if err := os.MkdirAll(util.GetCloverPath()+"/CloverPackage/sym", 0755); err != nil {
+				log.Fatal("Error: Failed to write build manifest: ", err)
 			}
+			if err := buildInfo.Write(util.GetCloverPath() + "/CloverPackage/sym/" + util.BuildManifestName); err != nil {
+				log.Fatal("Error: Failed to write build manifest: ", err)
+			}
+			if err := buildInfo.Write(filepath.Join(artifactPath, util.BuildManifestName)); err != nil {
+				log.Fatal("Error: Failed to write build manifest: ", err)
+			}
+			if err := util.WriteChecksums(artifactPath); err != nil {
+				log.Fatal("Error: Failed to write artifact checksums: ", err)
+			}
+			emitArtifactEvents(artifactPath)
 		}
 
+		// Save the successful build to the history
+		runRecord.ArtifactPath = artifactPath
+		finishRunRecord(util.RunSucceeded)
+
 		// Stop the execution timer
 		executionElapsedTime := util.GenerateTimeString(time.Since(executionStartTime))
 		executionResult := fmt.Sprintf("\n🎉  Finished in %s 🎉\n", executionElapsedTime)
 
-		// Stop the spinner
-		if !Verbose && !Quiet {
-			log.Debug(executionResult)
-			Spinner.FinalMSG = executionResult
-			Spinner.Stop()
-		} else {
-			log.Info(executionResult)
+		// Stop showing progress
+		log.Info(executionResult)
+		progress.Stop(executionResult + "\n")
+
+		// Show where the artifacts ended up
+		if len(artifactPath) > 0 {
+			printArtifacts(artifactPath)
 		}
-		fmt.Println()
 	},
 }
 
@@ -605,14 +657,22 @@ func init() {
 	// Add persistent flags that carry over to all commands
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "enable verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&Quiet, "quiet", "q", false, "silence all output")
-	rootCmd.PersistentFlags().StringVarP(&Revision, "revision", "r", "master", "Clover target revision")
+	rootCmd.PersistentFlags().StringVarP(&Revision, "revision", "r", "master", "Clover target revision (branch, tag, commit SHA, "+util.LatestReleaseRevision+" or "+util.LatestTagRevision+")")
 	rootCmd.PersistentFlags().BoolVarP(&BuildOnly, "build-only", "b", false, "only build (no update)")
 	rootCmd.PersistentFlags().BoolVarP(&UpdateOnly, "update-only", "u", false, "only update (no build)")
 	rootCmd.PersistentFlags().BoolVarP(&InstallerOnly, "installer-only", "i", false, "only build the installer")
 	rootCmd.PersistentFlags().BoolVarP(&NoClean, "no-clean", "n", false, "skip cleaning of dirty files")
 	rootCmd.PersistentFlags().StringVarP(&Toolchain, "toolchain", "t", "XCODE8", "toolchain to use for building")
+	rootCmd.PersistentFlags().StringVarP(&SourceDir, "source-dir", "", "", "build an existing Clover checkout in place (no git commands are run)")
 	// rootCmd.PersistentFlags().StringVarP(&Toolchain, "toolchain", "t", "GCC53", "toolchain to use for building")
+	rootCmd.PersistentFlags().StringVarP(&Workspace, "workspace", "w", util.DefaultWorkspace, "workspace to use (see 'clobber workspace')")
+	rootCmd.PersistentFlags().StringVarP(&Output, "output", "", "", "directory for build artifacts (default is the workspace's artifacts directory)")
+	rootCmd.PersistentFlags().BoolVarP(&CleanEnv, "clean-env", "", false, "only pass an allowlist of host environment variables to the build")
+	rootCmd.PersistentFlags().BoolVarP(&Wait, "wait", "", false, "wait for other runs in the same workspace to finish")
+	rootCmd.PersistentFlags().StringVarP(&OutputFormat, "output-format", "", textOutputFormat, "output format, either "+textOutputFormat+" or "+jsonOutputFormat+" (newline-delimited events, implies --quiet)")
+	rootCmd.PersistentFlags().BoolVarP(&TUI, "tui", "", false, "show a full-screen build dashboard")
 	rootCmd.PersistentFlags().BoolVarP(&Hiss, "hiss", "", false, "that's Sir Hiss to you")
+	rootCmd.PersistentFlags().BoolVarP(&Autopilot, "autopilot", "", false, "let the snake play itself (until an arrow key is pressed)")
 }
 
 func customInit() {
@@ -625,6 +685,42 @@ func customInit() {
 	// Assign our logger to use the custom formatter
 	log.Formatter = formatter
 
+	// Replace all human readable output with events when using the JSON output format
+	if OutputFormat != textOutputFormat && OutputFormat != jsonOutputFormat {
+		log.Fatal("Error: Invalid output format '" + OutputFormat + "', use " + textOutputFormat + " or " + jsonOutputFormat)
+	}
+	if jsonOutput() {
+		Quiet = true
+		log.AddHook(&eventHook{})
+	}
+
+	// The dashboard needs the whole terminal, and shows the logs itself (in verbose mode)
+	if TUI {
+		if jsonOutput() || Quiet {
+			log.Fatal("Error: Cannot use --tui with --quiet or --output-format " + jsonOutputFormat)
+		}
+		if !terminal.IsTerminal(int(os.Stdout.Fd())) {
+			log.Fatal("Error: Cannot use --tui without a terminal")
+		}
+	}
+
+	// Pick the progress output (dashboard, spinner, plain lines or nothing) and honor NO_COLOR
+	progress = newProgressRenderer()
+	if !useColor() {
+		formatter.DisableColors = true
+	}
+	if hook, ok := progress.(logrus.Hook); ok {
+		log.AddHook(hook)
+	}
+
+	// Switch to the selected workspace before using any workspace paths
+	if err := util.SetWorkspace(Workspace); err != nil {
+		log.Fatal("Error: ", err)
+	}
+	if !util.WorkspaceExists(util.GetWorkspace()) {
+		log.Fatal("Error: Workspace '" + util.GetWorkspace() + "' does not exist, create it with 'clobber workspace create " + util.GetWorkspace() + "'")
+	}
+
 	// Ensure the log file folder exists
 	mkdirErr := os.MkdirAll(util.GetLogsPath(), 0755)
 	if mkdirErr != nil {
@@ -639,21 +735,23 @@ func customInit() {
 		MaxAge:     90,    // Days to keep files
 		Compress:   false, // Compress log files (disabled by default)
 	}
-	lumberjackLogger.Rotate()
-	logMultiWriter := io.MultiWriter(os.Stdout, lumberjackLogger)
-	if Quiet || !Verbose {
-		// Disable logging to console if running in quiet/non-verbose mode
-		logMultiWriter = io.MultiWriter(ioutil.Discard, lumberjackLogger)
+	if Quiet || !Verbose || TUI || Hiss {
+		// Disable logging to console if running in quiet/non-verbose mode (or showing the dashboard or game)
+		logConsole = ioutil.Discard
 	}
-	log.SetOutput(logMultiWriter)
+	log.SetOutput(io.MultiWriter(logConsole, lumberjackLogger))
 
 	// Set default log level
 	log.Level = logrus.DebugLevel
 
 	// Setup our custom error writer hook, which prints errors in quiet/non-verbose mode
-	if Quiet || !Verbose {
+	if Quiet || !Verbose || TUI || Hiss {
+		errorWriter := io.Writer(os.Stderr)
+		if renderer, ok := progress.(fullScreenRenderer); ok {
+			errorWriter = renderer.errorOutput()
+		}
 		log.AddHook(&ErrorWriterHook{
-			Writer: os.Stderr,
+			Writer: errorWriter,
 			LogLevels: []logrus.Level{
 				logrus.PanicLevel,
 				logrus.FatalLevel,
@@ -663,7 +761,146 @@ func customInit() {
 	}
 }
 
-func runCommand(command string, dir string) error {
+// prepareBuild builds the base tools and prepares the toolchain and dependencies,
+// returning a function for cleaning up, which should be deferred until the build is done
+func prepareBuild(buildEnv *util.BuildEnv) func() {
+	var cleanups []func()
+
+	// Build base tools
+	beginStep("base-tools", "Building base tools")
+	if err := runCommand("make -C BaseTools/Source/C", util.GetCloverPath(), buildEnv); err != nil {
+		if err := runCommand("make clean -C BaseTools/Source/C", util.GetCloverPath(), buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		if err := runCommand("make -C BaseTools/Source/C", util.GetCloverPath(), buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+	}
+	endStep()
+
+	// Setup EDK
+	beginStep("edk-setup", "Setting up EDK")
+	if err := runCommand("source ./edksetup.sh BaseTools", util.GetCloverPath(), buildEnv); err != nil {
+		log.Fatal("Error: Failure detected, aborting\n", err)
+	}
+	endStep()
+
+	// Build gettext, mtoc and nasm (if necessary)
+	if _, err := os.Stat(util.GetSourcePath() + "/opt/local/bin/gettext"); os.IsNotExist(err) {
+		beginStep("link-gettext", "Linking gettext")
+		if err := runCommand("brew link gettext --force --overwrite", "", buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		cleanups = append(cleanups, func() { runCommand("brew unlink gettext", "", buildEnv) })
+		if err := runCommand("mkdir -p "+util.GetSourcePath()+"/opt/local/bin", "", buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		if err := runCommand("ln -sf /usr/local/bin/gettext "+util.GetSourcePath()+"/opt/local/bin/gettext", "", buildEnv); err != nil {
+			// if err := runCommand("ln -sf $(which gettext) "+util.GetSourcePath()+"/opt/local/bin/gettext", ""); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		endStep()
+	}
+	if _, err := os.Stat(util.GetSourcePath() + "/opt/local/bin/mtoc.NEW"); os.IsNotExist(err) {
+		beginStep("build-mtoc", "Building mtoc")
+		if err := runCommand(util.GetCloverPath()+"/buildmtoc.sh", "", buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		endStep()
+	}
+	if _, err := os.Stat(util.GetSourcePath() + "/opt/local/bin/nasm"); os.IsNotExist(err) {
+		beginStep("link-nasm", "Linking nasm")
+		// TODO: This could be done better, checking if linking/unlinking is even necessary
+		if err := runCommand("brew link nasm --force --overwrite", "", buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		cleanups = append(cleanups, func() { runCommand("brew unlink nasm", "", buildEnv) })
+		if err := runCommand("mkdir -p "+util.GetSourcePath()+"/opt/local/bin", "", buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		if err := runCommand("ln -sf /usr/local/bin/nasm "+util.GetSourcePath()+"/opt/local/bin/nasm", "", buildEnv); err != nil {
+			// if err := runCommand("ln -sf $(which nasm) "+util.GetSourcePath()+"/opt/local/bin/nasm", ""); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		endStep()
+	}
+
+	// FIXME: GCC building doesn't work yet
+	// Build with GCC instead of XCODE
+	if Toolchain != "XCODE8" {
+		if err := runCommand("mkdir -p "+util.GetSourcePath()+"/opt/local/cross/bin", "", buildEnv); err != nil {
+			log.Fatal("Error: Failure detected, aborting\n", err)
+		}
+		if _, err := os.Stat(util.GetSourcePath() + "/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc"); os.IsNotExist(err) {
+			beginStep("link-gcc", "Linking gcc")
+			if err := runCommand("ln -sf /usr/local/bin/gcc-8 "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc", "", buildEnv); err != nil {
+				// if err := runCommand("ln -sf $(which gcc) "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc", ""); err != nil {
+				log.Fatal("Error: Failure detected, aborting\n", err)
+			}
+			endStep()
+		}
+		if _, err := os.Stat(util.GetSourcePath() + "/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc-ar"); os.IsNotExist(err) {
+			beginStep("link-gcc-ar", "Linking gcc-ar")
+			if err := runCommand("ln -sf /usr/local/bin/gcc-ar-8 "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc-ar", "", buildEnv); err != nil {
+				// if err := runCommand("ln -sf $(which gcc) "+util.GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc", ""); err != nil {
+				log.Fatal("Error: Failure detected, aborting\n", err)
+			}
+			endStep()
+		}
+	}
+
+	return func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+}
+
+// runningCommand is the command currently being run (nil if there's none)
+var runningCommand *exec.Cmd
+
+// runningCommandMutex guards the running command, which is killed when aborting,
+// along with runAborted and abortReason
+var runningCommandMutex sync.Mutex
+
+// abortReason is why the build was aborted (if it was)
+var abortReason string
+
+// abortBuild kills the running command (along with its children) and keeps any more commands
+// from running, which makes the build fail on the main goroutine, as only that one may exit
+// (the exit handlers would otherwise run concurrently on both)
+func abortBuild(reason string) {
+	runningCommandMutex.Lock()
+	defer runningCommandMutex.Unlock()
+	if runAborted {
+		return
+	}
+	runAborted = true
+	abortReason = reason
+	if runningCommand != nil && runningCommand.Process != nil {
+		syscall.Kill(-runningCommand.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// abortError returns why the build was aborted as an error (nil if it wasn't)
+func abortError() error {
+	runningCommandMutex.Lock()
+	defer runningCommandMutex.Unlock()
+	if !runAborted {
+		return nil
+	}
+	return errors.New(abortReason)
+}
+
+// runCommand runs a command with bash in the supplied directory and environment,
+// using the process environment if env is nil (eg. for git, which needs the user's configuration)
+func runCommand(command string, dir string, env *util.BuildEnv) error {
+	return runCommandWithOutput(command, dir, env, nil)
+}
+
+// runCommandWithOutput runs a command like runCommand, but also
+// streams its combined output to the supplied writer (if not nil)
+func runCommandWithOutput(command string, dir string, env *util.BuildEnv, output io.Writer) error {
 	// If there are no args (or no spaces), we need to deal with those situations too
 	var (
 		cmd        string
@@ -681,18 +918,59 @@ func runCommand(command string, dir string) error {
 	}
 
 	log.Debug("Running command: '" + cmd + " " + argsString + "'")
+	emitEvent(buildEvent{Event: "command", Command: cmd + " " + argsString, Dir: dir})
 
 	// runCmd := exec.Command(cmd, args...)
 	runCmd := exec.Command("bash", "-c", cmd+" "+argsString)
 	if len(dir) > 0 {
 		runCmd.Dir = dir
 	}
+	if env != nil {
+		runCmd.Env = env.Environ()
+	}
 
-	var (
-		cmdOut []byte
-		err    error
-	)
-	if cmdOut, err = runCmd.CombinedOutput(); err != nil {
+	// Run the command in its own process group, so it can be killed along with its children when aborting
+	runCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// Collect the combined output for logging, while optionally streaming it
+	// and writing it to the log of the current step
+	var cmdOutBuffer bytes.Buffer
+	writers := []io.Writer{&cmdOutBuffer}
+	if output != nil {
+		writers = append(writers, output)
+	}
+	if stepLog != nil {
+		fmt.Fprintf(stepLog, "$ %s %s\n", cmd, argsString)
+		writers = append(writers, stepLog)
+	}
+	if progressWriter, ok := progress.(io.Writer); ok {
+		writers = append(writers, progressWriter)
+	}
+	runCmd.Stdout = io.MultiWriter(writers...)
+	runCmd.Stderr = runCmd.Stdout
+
+	// Don't start anything once the build is aborted, and fail with the reason if it's aborted while running
+	runningCommandMutex.Lock()
+	if runAborted {
+		runningCommandMutex.Unlock()
+		return errors.New(abortReason)
+	}
+	err := runCmd.Start()
+	if err == nil {
+		runningCommand = runCmd
+	}
+	runningCommandMutex.Unlock()
+	if err == nil {
+		err = runCmd.Wait()
+		runningCommandMutex.Lock()
+		runningCommand = nil
+		if runAborted {
+			err = errors.New(abortReason)
+		}
+		runningCommandMutex.Unlock()
+	}
+	cmdOut := cmdOutBuffer.Bytes()
+	if err != nil {
 		// log.Fatal("Error: Failed to run '" + cmd + " " + argsString + "':\n" + string(cmdOut))
 		customErr := errors.New("Failed to run '" + cmd + " " + argsString + "':\n" + string(cmdOut))
 		log.Warn("Warning: " + customErr.Error())
@@ -715,10 +993,92 @@ func getGitHubReleaseLink(url string, filter string) string {
 	return strings.TrimSpace(string(out))
 }
 
-func formatSpinnerText(text string, done bool) string {
-	if done {
-		fmt.Printf("\r✔ %s  \n", text)
-		return fmt.Sprintf("\r✔ %s  \n", text)
+// preserveFile stores the original contents of a file in an external
+// Clover checkout, so it can be restored after we're done patching it
+func preserveFile(path string) {
+	if !util.IsExternalCloverPath() {
+		return
+	}
+	if _, ok := preservedFiles[path]; ok {
+		return
+	}
+	fileContents, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Debug("Not preserving ", path, ": ", err)
+		return
+	}
+	preservedFiles[path] = fileContents
+}
+
+// restorePreservedFiles writes back all files stored by preserveFile
+func restorePreservedFiles() {
+	for path, fileContents := range preservedFiles {
+		log.Debug("Restoring ", path)
+		if err := ioutil.WriteFile(path, fileContents, 0644); err != nil {
+			log.Warn("Warning: Failed to restore ", path, ": ", err)
+		}
+		delete(preservedFiles, path)
+	}
+}
+
+// lockWorkspace acquires the workspace lock (optionally waiting for it), which the caller
+// releases once done, including in the last exit handler (if the process exits before that,
+// the lock is dropped along with the file descriptor, leaving only the lock file behind)
+func lockWorkspace() *util.Lock {
+	if err := os.MkdirAll(util.GetClobberPath(), 0755); err != nil {
+		log.Fatal("Error: MkdirAll failed with error: ", err)
 	}
-	return fmt.Sprintf("\r◌ %s ", text)
+	waiting := false
+	for {
+		lock, err := util.AcquireLock(util.GetLockPath())
+		if err == nil {
+			log.Debug("Acquired workspace lock " + util.GetLockPath())
+			return lock
+		}
+		lockedErr, ok := err.(*util.LockedError)
+		if !ok {
+			log.Fatal("Error: Failed to lock workspace: ", err)
+		}
+		if abortErr := abortError(); abortErr != nil {
+			log.Fatal("Error: ", abortErr)
+		}
+		if !Wait {
+			log.Fatal("Error: Workspace '" + util.GetWorkspace() + "' is in use, " + lockedErr.Error() + "\nUse --wait to wait for it to finish, or --workspace to use a different workspace")
+		}
+		if !waiting {
+			log.Debug("Waiting for workspace lock, " + lockedErr.Error())
+			printInfo(fmt.Sprintf("Waiting for PID %d to finish (%s)", lockedErr.Holder.PID, lockedErr.Holder.Command))
+			waiting = true
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// setupSourceDir switches to building the checkout from --source-dir,
+// returning true if one was supplied
+func setupSourceDir() bool {
+	if len(SourceDir) == 0 {
+		return false
+	}
+	sourceDir, absErr := filepath.Abs(SourceDir)
+	if absErr != nil {
+		log.Fatal("Error: Failed to resolve --source-dir: ", absErr)
+	}
+	if _, err := os.Stat(sourceDir + "/ebuild.sh"); err != nil {
+		log.Fatal("Error: " + sourceDir + " does not look like a Clover checkout (ebuild.sh is missing)")
+	}
+	util.SetCloverPath(sourceDir)
+	return true
+}
+
+// printInfo logs a message and shows it alongside the progress output
+func printInfo(text string) {
+	log.Info(text)
+	progress.Info(text)
+}
+
+// printWarning logs a warning and shows it alongside the progress output
+func printWarning(text string) {
+	log.Warn("Warning: " + text)
+	progress.Warning(text)
 }
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Dids/clobber/snake"
+	"github.com/Dids/clobber/util"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// SnakeReplay is the replay to play back instead of playing
+var SnakeReplay string
+
+// SnakeSpeed is how fast replays are played back
+var SnakeSpeed float64
+
+// SnakeScores shows the highscores instead of playing
+var SnakeScores bool
+
+// SnakeMode is the game mode (overriding the config)
+var SnakeMode string
+
+// SnakeMap is the map of the obstacles mode (overriding the config)
+var SnakeMap string
+
+// SnakePlayers is the number of players sharing the keyboard
+var SnakePlayers int
+
+// SnakeRounds is how many rounds a two player match is the best of
+var SnakeRounds int
+
+// snakeCmd plays snake without building anything, or plays back a replay
+var snakeCmd = &cobra.Command{
+	Use:   "snake",
+	Short: "Play snake (or watch a replay)",
+	Long: `Play snake without building anything (see --hiss for playing while building).
+
+The best games are kept as replays in ~/.clobber/replays, which can be played back
+with --replay, either by path, by name or as "best" for the best one:
+
+  clobber snake --replay best --speed 2
+
+The game modes are classic, wrap (no walls), obstacles (with the walls of a map)
+and campaign (moving on to the next level as the score increases). Maps are plain
+text files where '#' is a wall, stretched to fit the level, and can be chosen by
+path, by name in ~/.clobber/maps or as one of the built-in maps:
+
+  clobber snake --mode obstacles --map pillars
+
+The mode (and map) can also be set in ~/.clobber/snake.json, eg. {"mode": "wrap"}.
+
+The highscores of each mode are kept in ~/.clobber/highscores.json, and shown with --scores.
+
+Two players can play against each other on the same keyboard (arrow keys vs. WASD),
+with the snakes colliding with each other, in a match of the best of --rounds rounds:
+
+  clobber snake --players 2 --rounds 5`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := snake.LoadConfig(util.GetSnakeConfigPath())
+		if err != nil {
+			log.Fatal("Error: Failed to load the snake config: ", err)
+		}
+		if cmd.Flags().Changed("mode") || cmd.Flags().Changed("map") {
+			config.Mode, config.Map = SnakeMode, SnakeMap
+		}
+		mode, err := config.GameMode()
+		if err != nil {
+			log.Fatal("Error: Invalid game mode: ", err)
+		}
+
+		if SnakeScores {
+			if !cmd.Flags().Changed("mode") && !cmd.Flags().Changed("map") {
+				mode = nil
+			}
+			printHighscores(config, mode)
+			return
+		}
+		if !terminal.IsTerminal(int(os.Stdout.Fd())) {
+			log.Fatal("Error: Snake needs a terminal")
+		}
+
+		if len(SnakeReplay) == 0 {
+			if SnakePlayers != 1 && SnakePlayers != 2 {
+				log.Fatal("Error: Snake can only be played by 1 or 2 players")
+			}
+			if SnakeRounds < 1 {
+				log.Fatal("Error: A match needs at least one round")
+			}
+			if SnakePlayers > 1 && Autopilot {
+				log.Fatal("Error: Cannot use --autopilot with two players")
+			}
+			game := snake.NewGame(config)
+			if SnakePlayers > 1 {
+				game.EnableTwoPlayers(SnakeRounds)
+			}
+			if Autopilot {
+				game.EnableAutopilot()
+			}
+			game.Start()
+			return
+		}
+		if SnakeSpeed <= 0 {
+			log.Fatal("Error: The replay speed has to be positive")
+		}
+		replay, err := findReplay(SnakeReplay)
+		if err != nil {
+			log.Fatal("Error: Failed to load replay: ", err)
+		}
+		snake.NewReplayGame(config, replay, SnakeSpeed).Start()
+	},
+}
+
+// findReplay loads a replay by path, by its name in the replays directory, or the best one ("best")
+func findReplay(name string) (*snake.Replay, error) {
+	if name == "best" {
+		replays, err := snake.ListReplays(util.GetReplaysPath())
+		if err != nil {
+			return nil, err
+		}
+		if len(replays) == 0 {
+			return nil, fmt.Errorf("no replays in %s", util.GetReplaysPath())
+		}
+		return replays[0], nil
+	}
+	for _, path := range []string{name, filepath.Join(util.GetReplaysPath(), name), filepath.Join(util.GetReplaysPath(), name+".json")} {
+		if _, err := os.Stat(path); err == nil {
+			return snake.LoadReplay(path)
+		}
+	}
+	return nil, fmt.Errorf("no replay named '%s'", name)
+}
+
+// printHighscores prints the highscore table of the supplied mode, or of all modes if nil
+func printHighscores(config *snake.Config, mode *snake.Mode) {
+	if err := snake.MigrateScoreFile(util.GetScorePath(), util.GetHighscoresPath(), config.PlayerName()); err != nil {
+		log.Fatal("Error: Failed to migrate the old highscore: ", err)
+	}
+	highscores, err := snake.LoadHighscores(util.GetHighscoresPath())
+	if err != nil {
+		log.Fatal("Error: Failed to load the highscores: ", err)
+	}
+	if mode != nil {
+		highscores = snake.HighscoresForMode(highscores, mode.ID())
+	}
+	if len(highscores) == 0 {
+		fmt.Println("No highscores in " + util.GetHighscoresPath())
+		return
+	}
+
+	// Group the entries by mode, ranking them within their mode
+	sort.SliceStable(highscores, func(i, j int) bool { return highscores[i].Mode < highscores[j].Mode })
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "RANK\tNAME\tSCORE\tMODE\tDURATION\tDATE\tREVISION\tREPLAY")
+	rank := 0
+	for i, entry := range highscores {
+		rank++
+		if i > 0 && highscores[i-1].Mode != entry.Mode {
+			rank = 1
+		}
+		date, revision, replay := "-", "-", "-"
+		if !entry.Date.IsZero() {
+			date = entry.Date.Local().Format("2006-01-02 15:04")
+		}
+		if len(entry.Revision) > 0 {
+			revision = entry.Revision
+		}
+		if len(entry.Replay) > 0 {
+			replay = entry.Replay
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", rank, entry.Name, entry.Score, entry.Mode, formatDuration(entry.Duration), date, revision, replay)
+	}
+	writer.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(snakeCmd)
+	snakeCmd.Flags().StringVarP(&SnakeReplay, "replay", "", "", "play back a replay (path, name or \"best\")")
+	snakeCmd.Flags().Float64VarP(&SnakeSpeed, "speed", "", 1, "replay speed (eg. 2 for twice as fast)")
+	snakeCmd.Flags().IntVarP(&SnakePlayers, "players", "", 1, "number of players (1 or 2, with the second one using WASD)")
+	snakeCmd.Flags().IntVarP(&SnakeRounds, "rounds", "", 3, "number of rounds a two player match is the best of")
+	snakeCmd.Flags().BoolVarP(&SnakeScores, "scores", "", false, "show the highscores (of all modes, unless --mode is set)")
+	snakeCmd.Flags().StringVarP(&SnakeMode, "mode", "", "", "game mode ("+strings.Join(snake.ModeNames, ", ")+")")
+	snakeCmd.Flags().StringVarP(&SnakeMap, "map", "", "", "map of the obstacles mode (path, name or one of "+strings.Join(snake.PresetMapNames(), ", ")+")")
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Dids/clobber/util"
+)
+
+// buildStep is a single step of the build process
+type buildStep struct {
+	// ID of the step (eg. "build-boot7")
+	ID string
+
+	// Title shown to the user (eg. "Building Clover (boot7)")
+	Title string
+
+	// Start time of the step
+	started time.Time
+}
+
+// pipeline lists the steps the current run is expected to take, in order
+var pipeline []*buildStep
+
+// currentStep is the step in progress (nil if there's none)
+var currentStep *buildStep
+
+// completedSteps lists all finished steps and their durations
+var completedSteps []util.StepInfo
+
+// stepsMutex guards the steps, which the spinner reads when showing progress
+var stepsMutex sync.Mutex
+
+// buildEstimate predicts step durations from previous builds (nil if there are none)
+var buildEstimate *util.BuildEstimate
+
+// beginStep marks the start of a build step
+func beginStep(id string, title string) {
+	step := &buildStep{ID: id, Title: title, started: time.Now()}
+	stepsMutex.Lock()
+	currentStep = step
+	stepsMutex.Unlock()
+
+	closeStepLog()
+	openStepLog(id)
+	if expected, ok := buildEstimate.Step(id); ok {
+		log.Debug(title + ".. (expected to take " + util.GenerateTimeString(expected) + ")")
+	} else {
+		log.Debug(title + "..")
+	}
+	progress.StepStarted(step)
+}
+
+// endStep marks the current build step as finished, recording its duration
+func endStep() {
+	stepsMutex.Lock()
+	step := currentStep
+	if step == nil {
+		stepsMutex.Unlock()
+		return
+	}
+	duration := time.Since(step.started)
+	completedSteps = append(completedSteps, util.StepInfo{
+		ID:       step.ID,
+		Title:    step.Title,
+		Duration: duration,
+	})
+	currentStep = nil
+	stepsMutex.Unlock()
+
+	closeStepLog()
+	progress.StepFinished(step, duration)
+}
+
+// buildPipeline returns the steps a build with the current flags is expected to take, in order
+func buildPipeline() []*buildStep {
+	var steps []*buildStep
+	add := func(id string, title string) {
+		steps = append(steps, &buildStep{ID: id, Title: title})
+	}
+	external := util.IsExternalCloverPath()
+
+	add("verify-folders", "Verifying folder structure")
+	if _, err := os.Stat(util.GetCloverPath() + "/.git"); os.IsNotExist(err) && !external {
+		add("download", "Downloading Clover")
+	}
+	if !BuildOnly && !InstallerOnly && !external {
+		add("update", "Verifying Clover is up to date")
+	}
+	if !UpdateOnly && !InstallerOnly {
+		steps = append(steps, preparePipeline()...)
+		add("patch", "Patching Clover")
+		add("build-clean", "Cleaning Clover")
+		add("build-boot6", "Building Clover (boot6)")
+		add("build-boot7", "Building Clover (boot7)")
+	}
+	if !BuildOnly && !InstallerOnly {
+		add("drivers", "Updating extra EFI drivers")
+	}
+	if !UpdateOnly {
+		add("patch-installer", "Patching Clover installer")
+		add("build-installer", "Building Clover installer")
+		if !InstallerOnly {
+			add("build-iso", "Building Clover ISO image")
+		}
+		add("collect", "Collecting artifacts")
+	}
+	return steps
+}
+
+// preparePipeline returns the steps prepareBuild is expected to take, in order
+// (the dependencies are only set up if they're missing)
+func preparePipeline() []*buildStep {
+	var steps []*buildStep
+	add := func(id string, title string) {
+		steps = append(steps, &buildStep{ID: id, Title: title})
+	}
+	missing := func(path string) bool {
+		_, err := os.Stat(util.GetSourcePath() + path)
+		return os.IsNotExist(err)
+	}
+
+	add("base-tools", "Building base tools")
+	add("edk-setup", "Setting up EDK")
+	if missing("/opt/local/bin/gettext") {
+		add("link-gettext", "Linking gettext")
+	}
+	if missing("/opt/local/bin/mtoc.NEW") {
+		add("build-mtoc", "Building mtoc")
+	}
+	if missing("/opt/local/bin/nasm") {
+		add("link-nasm", "Linking nasm")
+	}
+	if Toolchain != "XCODE8" {
+		if missing("/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc") {
+			add("link-gcc", "Linking gcc")
+		}
+		if missing("/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc-ar") {
+			add("link-gcc-ar", "Linking gcc-ar")
+		}
+	}
+	return steps
+}
+
+// startProgressEstimate loads the durations of previous builds with the same
+// toolchain, which are used for showing the progress of each step
+func startProgressEstimate() {
+	records, err := util.ReadRunRecords(util.GetHistoryPath())
+	if err != nil {
+		log.Debug("Not estimating progress, failed to read build history: " + err.Error())
+		return
+	}
+	buildEstimate = util.NewBuildEstimate(records, Toolchain, os.Args[1:])
+	if buildEstimate == nil {
+		log.Debug("Not estimating progress, no previous builds with toolchain " + Toolchain)
+	}
+}
+
+// formatRemainingTime returns a human readable estimate of the remaining time
+func formatRemainingTime(remaining time.Duration) string {
+	if remaining < time.Second {
+		return "almost done"
+	}
+	if remaining >= time.Minute {
+		remaining = remaining.Round(time.Minute)
+	}
+	return "about " + util.GenerateTimeString(remaining) + " left"
+}
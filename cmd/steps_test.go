@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestBuildPipeline(t *testing.T) {
+	rootPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(rootPath)
+	defer os.Setenv("CLOBBER_HOME", os.Getenv("CLOBBER_HOME"))
+	os.Setenv("CLOBBER_HOME", rootPath)
+	defer func(toolchain string, buildOnly, updateOnly, installerOnly bool) {
+		Toolchain, BuildOnly, UpdateOnly, InstallerOnly = toolchain, buildOnly, updateOnly, installerOnly
+	}(Toolchain, BuildOnly, UpdateOnly, InstallerOnly)
+
+	// Without a Clover checkout or any dependencies, every step is expected to run
+	Toolchain, BuildOnly, UpdateOnly, InstallerOnly = "GCC53", false, false, false
+	titles := make(map[string]string)
+	for _, step := range buildPipeline() {
+		titles[step.ID] = step.Title
+	}
+
+	// The pipeline has to match the steps the build actually takes
+	stepRegex := regexp.MustCompile(`beginStep\("([^"]+)", "([^"]+)"\)`)
+	source, err := ioutil.ReadFile("root.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := stepRegex.FindAllStringSubmatch(string(source), -1)
+	if len(matches) != len(titles) {
+		t.Errorf("Expected %d steps in the pipeline, got %d", len(matches), len(titles))
+	}
+	for _, match := range matches {
+		if title, ok := titles[match[1]]; !ok || title != match[2] {
+			t.Errorf("Step %s (%s) doesn't match the pipeline: %q", match[1], match[2], title)
+		}
+	}
+
+	// Only building leaves out updating Clover and the drivers
+	BuildOnly = true
+	for _, step := range buildPipeline() {
+		if step.ID == "update" || step.ID == "drivers" {
+			t.Errorf("Unexpected step %s when only building", step.ID)
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dids/clobber/util"
+	"github.com/nsf/termbox-go"
+	logrus "github.com/sirupsen/logrus"
+)
+
+// TUI enables the full-screen build dashboard
+var TUI bool
+
+// tuiMaxLines is the number of output lines kept for the live tail
+const tuiMaxLines = 1000
+
+// Smallest terminal size the dashboard can be drawn in
+const (
+	tuiMinWidth  = 40
+	tuiMinHeight = 5
+)
+
+// tuiLine is a single line in the live tail
+type tuiLine struct {
+	text string
+
+	// Log messages are only shown in verbose mode, unlike command output
+	log bool
+
+	color termbox.Attribute
+}
+
+// tuiRenderer shows the build progress as a full-screen dashboard with a step list and a live tail
+type tuiRenderer struct {
+	mutex sync.Mutex
+
+	// Output of the running command and log messages
+	lines []tuiLine
+
+	// Incomplete last line of command output
+	partialLine string
+
+	// Steps that have finished so far, and the ones the run is expected to take (both in order)
+	finishedSteps []util.StepInfo
+	pipeline      []*buildStep
+
+	// Whether the live tail is paused (frozen), and the number of lines it showed when paused
+	paused      bool
+	pausedLines int
+
+	// Whether log messages are shown in the live tail
+	verbose bool
+
+	// Set once the dashboard is running, and the error that ended the build (if any)
+	active  bool
+	failure string
+
+	// Revision and start time of the build shown in the header (copied from runRecord,
+	// which belongs to the main goroutine, so the redraw ticker never reads it)
+	revision string
+	started  time.Time
+
+	// Errors written to the terminal while the dashboard is shown
+	errors *heldWriter
+
+	// Closed when the event loop and the redraw ticker should stop
+	stop chan struct{}
+
+	// Receives a key press when waiting for the user to close the dashboard
+	acknowledged chan struct{}
+
+	// Waits for the event loop to finish before closing termbox
+	eventsDone sync.WaitGroup
+}
+
+// newTUIRenderer creates a new dashboard, which is shown once started
+func newTUIRenderer(verbose bool) *tuiRenderer {
+	return &tuiRenderer{
+		verbose:      verbose,
+		stop:         make(chan struct{}),
+		acknowledged: make(chan struct{}, 1),
+		errors:       &heldWriter{out: os.Stderr},
+	}
+}
+
+// Banner doesn't show anything, as the dashboard has its own header
+func (renderer *tuiRenderer) Banner() {
+}
+
+// Start switches the terminal to the dashboard
+func (renderer *tuiRenderer) Start() {
+	renderer.mutex.Lock()
+	active := renderer.active
+	renderer.mutex.Unlock()
+	if active {
+		return
+	}
+	if err := termbox.Init(); err != nil {
+		log.Fatal("Error: Failed to start the dashboard: ", err)
+	}
+	termbox.SetInputMode(termbox.InputEsc)
+	renderer.errors.hold()
+	renderer.updateHeader()
+
+	renderer.mutex.Lock()
+	defer renderer.mutex.Unlock()
+	renderer.active = true
+	renderer.pipeline = pipeline
+
+	renderer.eventsDone.Add(1)
+	go renderer.handleEvents()
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renderer.stop:
+				return
+			case <-ticker.C:
+				renderer.redraw()
+			}
+		}
+	}()
+	renderer.draw()
+}
+
+// StepStarted redraws the step list (and the header, as the revision may have been resolved)
+func (renderer *tuiRenderer) StepStarted(step *buildStep) {
+	renderer.updateHeader()
+	renderer.redraw()
+}
+
+// updateHeader copies the revision and start time of the build for the header
+// (called from the main goroutine, which owns runRecord)
+func (renderer *tuiRenderer) updateHeader() {
+	record := runRecord
+	if record == nil {
+		return
+	}
+	revision := record.Describe
+	if len(revision) == 0 {
+		revision = record.Revision
+	}
+	renderer.mutex.Lock()
+	renderer.revision = revision
+	renderer.started = record.Started
+	renderer.mutex.Unlock()
+}
+
+// StepFinished adds the step to the list of finished steps
+func (renderer *tuiRenderer) StepFinished(step *buildStep, duration time.Duration) {
+	renderer.mutex.Lock()
+	renderer.finishedSteps = append(renderer.finishedSteps, util.StepInfo{ID: step.ID, Title: step.Title, Duration: duration})
+	renderer.mutex.Unlock()
+	renderer.redraw()
+}
+
+// Info adds a message to the live tail
+func (renderer *tuiRenderer) Info(text string) {
+	renderer.addLine(tuiLine{text: "→ " + text, color: termbox.ColorCyan})
+}
+
+// Warning adds a warning to the live tail
+func (renderer *tuiRenderer) Warning(text string) {
+	renderer.addLine(tuiLine{text: "⚠ " + text, color: termbox.ColorYellow})
+}
+
+// Stop restores the terminal and shows the final message, first showing the error that
+// ended the build until a key is pressed (unless the user aborted the build)
+func (renderer *tuiRenderer) Stop(message string) {
+	renderer.mutex.Lock()
+	waiting := renderer.active && len(renderer.failure) > 0 && abortError() == nil
+	renderer.mutex.Unlock()
+	if waiting {
+		select {
+		case <-renderer.acknowledged:
+		case <-renderer.stop:
+		}
+	}
+	renderer.close()
+	renderer.errors.release()
+	fmt.Print(message)
+}
+
+// errorOutput returns the writer for errors, which are printed once the dashboard is closed
+func (renderer *tuiRenderer) errorOutput() io.Writer {
+	return renderer.errors
+}
+
+// Write adds the output of a command to the live tail
+func (renderer *tuiRenderer) Write(data []byte) (int, error) {
+	renderer.mutex.Lock()
+	text := renderer.partialLine + string(data)
+	lines := strings.Split(text, "\n")
+	renderer.partialLine = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		renderer.appendLine(tuiLine{text: strings.TrimRight(line, "\r")})
+	}
+	renderer.mutex.Unlock()
+	return len(data), nil
+}
+
+// Fire adds log messages to the live tail, and shows the error that ends the build
+// (without waiting for it to be seen, as logrus holds its lock while firing hooks)
+func (renderer *tuiRenderer) Fire(entry *logrus.Entry) error {
+	if entry.Level > logrus.ErrorLevel {
+		// Only show the first line, as command output is already shown as it happens
+		message := strings.SplitN(entry.Message, "\n", 2)[0]
+		renderer.addLine(tuiLine{text: message, log: true, color: termbox.ColorBlue})
+		return nil
+	}
+	if entry.Level > logrus.FatalLevel {
+		renderer.addLine(tuiLine{text: entry.Message, color: termbox.ColorRed})
+		return nil
+	}
+
+	renderer.mutex.Lock()
+	renderer.failure = entry.Message
+	renderer.paused = false
+	renderer.mutex.Unlock()
+	renderer.redraw()
+	return nil
+}
+
+// Levels define on which log levels this hook would trigger
+func (renderer *tuiRenderer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// handleEvents handles key presses and resizing until the dashboard is closed
+func (renderer *tuiRenderer) handleEvents() {
+	defer renderer.eventsDone.Done()
+	for {
+		event := termbox.PollEvent()
+		switch event.Type {
+		case termbox.EventInterrupt:
+			return
+		case termbox.EventResize:
+			renderer.redraw()
+		case termbox.EventKey:
+			renderer.mutex.Lock()
+			failed := len(renderer.failure) > 0
+			renderer.mutex.Unlock()
+			if failed {
+				select {
+				case renderer.acknowledged <- struct{}{}:
+				default:
+				}
+				continue
+			}
+			switch {
+			case event.Ch == 'p' || event.Key == termbox.KeySpace:
+				renderer.mutex.Lock()
+				renderer.paused = !renderer.paused
+				renderer.pausedLines = len(renderer.lines)
+				renderer.mutex.Unlock()
+				renderer.redraw()
+			case event.Ch == 'v':
+				renderer.mutex.Lock()
+				renderer.verbose = !renderer.verbose
+				renderer.mutex.Unlock()
+				renderer.redraw()
+			case event.Ch == 'q' || event.Key == termbox.KeyCtrlC:
+				abortBuild("Aborted by user")
+			}
+		}
+	}
+}
+
+// close restores the terminal, if the dashboard is active
+func (renderer *tuiRenderer) close() {
+	renderer.mutex.Lock()
+	active := renderer.active
+	renderer.active = false
+	renderer.mutex.Unlock()
+	if !active {
+		return
+	}
+	close(renderer.stop)
+	termbox.Interrupt()
+	renderer.eventsDone.Wait()
+	termbox.Close()
+}
+
+// addLine adds a line to the live tail
+func (renderer *tuiRenderer) addLine(line tuiLine) {
+	renderer.mutex.Lock()
+	for _, text := range strings.Split(line.text, "\n") {
+		renderer.appendLine(tuiLine{text: text, log: line.log, color: line.color})
+	}
+	renderer.mutex.Unlock()
+	renderer.redraw()
+}
+
+// appendLine adds a line to the live tail, dropping the oldest line if necessary (needs the mutex)
+func (renderer *tuiRenderer) appendLine(line tuiLine) {
+	renderer.lines = append(renderer.lines, line)
+	if len(renderer.lines) > tuiMaxLines {
+		dropped := len(renderer.lines) - tuiMaxLines
+		renderer.lines = renderer.lines[dropped:]
+		renderer.pausedLines -= dropped
+		if renderer.pausedLines < 0 {
+			renderer.pausedLines = 0
+		}
+	}
+}
+
+// redraw draws the dashboard, if it's active
+func (renderer *tuiRenderer) redraw() {
+	renderer.mutex.Lock()
+	defer renderer.mutex.Unlock()
+	if renderer.active {
+		renderer.draw()
+	}
+}
+
+// draw draws the whole dashboard (needs the mutex)
+func (renderer *tuiRenderer) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	width, height := termbox.Size()
+	if width < tuiMinWidth || height < tuiMinHeight {
+		drawText(0, 0, width, "Terminal too small", termbox.ColorDefault, termbox.ColorDefault)
+		termbox.Flush()
+		return
+	}
+
+	stepsMutex.Lock()
+	step := currentStep
+	var elapsed time.Duration
+	if step != nil {
+		elapsed = time.Since(step.started)
+	}
+	finished := append([]util.StepInfo(nil), completedSteps...)
+	stepsMutex.Unlock()
+
+	// Header with the revision, toolchain and overall progress
+	header := " CLOBBER v" + Version + "  │  " + Toolchain + "  │  workspace " + util.GetWorkspace()
+	if len(renderer.revision) > 0 {
+		header += "  │  Clover " + renderer.revision
+	}
+	if !renderer.started.IsZero() {
+		header += "  │  " + formatDuration(time.Since(renderer.started))
+	}
+	if step != nil {
+		if percent, remaining, ok := buildEstimate.Progress(finished, step.ID, elapsed); ok {
+			header += fmt.Sprintf(" (%.0f%%, %s)", percent, formatRemainingTime(remaining))
+		}
+	}
+	drawText(0, 0, width, padRight(header, width), termbox.ColorBlack, termbox.ColorWhite)
+
+	// Step list on the left, live tail on the right
+	stepsWidth := width / 3
+	if stepsWidth > 48 {
+		stepsWidth = 48
+	}
+	for y := 1; y < height-1; y++ {
+		termbox.SetCell(stepsWidth, y, '│', termbox.ColorDefault, termbox.ColorDefault)
+	}
+	y := 1
+	for _, stepInfo := range renderer.stepList(step, elapsed) {
+		if y >= height-1 {
+			break
+		}
+		drawText(1, y, stepsWidth-1, stepInfo.text, stepInfo.color, termbox.ColorDefault)
+		y++
+	}
+	renderer.drawTail(stepsWidth+2, 1, width-stepsWidth-2, height-2)
+
+	// Footer with the key bindings
+	footer := " p pause  │  v verbose  │  q abort"
+	if len(renderer.failure) > 0 {
+		footer = " Build failed, press any key to exit"
+	} else {
+		if renderer.paused {
+			footer += "  │  PAUSED"
+		}
+		if renderer.verbose {
+			footer += "  │  VERBOSE"
+		}
+	}
+	drawText(0, height-1, width, padRight(footer, width), termbox.ColorBlack, termbox.ColorWhite)
+
+	termbox.Flush()
+}
+
+// tuiStepLine is a single line in the step list
+type tuiStepLine struct {
+	text  string
+	color termbox.Attribute
+}
+
+// stepList returns the finished, running and pending steps (needs the mutex)
+func (renderer *tuiRenderer) stepList(step *buildStep, elapsed time.Duration) []tuiStepLine {
+	var lines []tuiStepLine
+	seen := make(map[string]bool)
+	for _, finishedStep := range renderer.finishedSteps {
+		seen[finishedStep.ID] = true
+		lines = append(lines, tuiStepLine{"✔ " + finishedStep.Title + " " + formatDuration(finishedStep.Duration), termbox.ColorGreen})
+	}
+	if step != nil {
+		seen[step.ID] = true
+		if len(renderer.failure) > 0 {
+			lines = append(lines, tuiStepLine{"✘ " + step.Title + " " + formatDuration(elapsed), termbox.ColorRed})
+		} else {
+			lines = append(lines, tuiStepLine{"◌ " + step.Title + formatStepTiming(step, elapsed), termbox.ColorYellow | termbox.AttrBold})
+		}
+	}
+	for _, pendingStep := range renderer.pipeline {
+		if seen[pendingStep.ID] {
+			continue
+		}
+		text := "  " + pendingStep.Title
+		if expected, ok := buildEstimate.Step(pendingStep.ID); ok {
+			text += " ~" + formatDuration(expected)
+		}
+		lines = append(lines, tuiStepLine{text, termbox.ColorDefault})
+	}
+	return lines
+}
+
+// drawTail draws the last lines of output that fit in the given area (needs the mutex)
+func (renderer *tuiRenderer) drawTail(x int, y int, width int, height int) {
+	lines := renderer.lines
+	if renderer.paused && renderer.pausedLines <= len(lines) {
+		lines = lines[:renderer.pausedLines]
+	}
+	var visible []tuiLine
+	for _, line := range lines {
+		if !line.log || renderer.verbose {
+			visible = append(visible, line)
+		}
+	}
+	if len(renderer.failure) > 0 {
+		// Show the error that ended the build at the bottom
+		for _, text := range strings.Split(renderer.failure, "\n") {
+			visible = append(visible, tuiLine{text: text, color: termbox.ColorRed})
+		}
+	}
+	if len(visible) > height {
+		visible = visible[len(visible)-height:]
+	}
+	for i, line := range visible {
+		drawText(x, y+i, width, line.text, line.color, termbox.ColorDefault)
+	}
+}
+
+// drawText draws text, cutting it off at the given width
+func drawText(x int, y int, width int, text string, fg termbox.Attribute, bg termbox.Attribute) {
+	column := 0
+	for _, r := range text {
+		if column >= width {
+			break
+		}
+		if r == '\t' {
+			r = ' '
+		}
+		termbox.SetCell(x+column, y, r, fg, bg)
+		column++
+	}
+}
+
+// padRight pads text with spaces to the given width
+func padRight(text string, width int) string {
+	if length := len([]rune(text)); length < width {
+		return text + strings.Repeat(" ", width-length)
+	}
+	return text
+}
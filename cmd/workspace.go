@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Dids/clobber/util"
+	"github.com/spf13/cobra"
+)
+
+// ForceRemoveWorkspace skips the confirmation when removing a workspace
+var ForceRemoveWorkspace bool
+
+// workspaceCmd is the parent command for managing workspaces
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Manage workspaces",
+	Long: `Manage workspaces, each of which has its own sources, logs and artifacts.
+
+The default workspace lives in the Clobber root directory (CLOBBER_HOME, or
+~/.clobber if not set), while named workspaces live in its "workspaces" directory.
+Select a workspace with --workspace (eg. 'clobber --workspace nightly').`,
+}
+
+// workspaceListCmd lists all workspaces
+var workspaceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all workspaces",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspaces, err := util.ListWorkspaces()
+		if err != nil {
+			log.Fatal("Error: Failed to list workspaces: ", err)
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(writer, "\tWORKSPACE\tCLOVER\tPATH")
+		for _, name := range workspaces {
+			active := ""
+			if name == util.GetWorkspace() {
+				active = "*"
+			}
+			cloverRevision := "-"
+			if _, describe, err := util.DescribeRevision(util.GetWorkspacePath(name)+"/src/Clover", "HEAD"); err == nil {
+				cloverRevision = describe
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", active, name, cloverRevision, util.GetWorkspacePath(name))
+		}
+		writer.Flush()
+	},
+}
+
+// workspaceCreateCmd creates a new named workspace
+var workspaceCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new workspace",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if err := util.ValidateWorkspaceName(name); err != nil {
+			log.Fatal("Error: ", err)
+		}
+		if util.WorkspaceExists(name) {
+			log.Fatal("Error: Workspace '" + name + "' already exists")
+		}
+		for _, path := range []string{"/src", "/logs"} {
+			if err := os.MkdirAll(util.GetWorkspacePath(name)+path, 0755); err != nil {
+				log.Fatal("Error: Failed to create workspace: ", err)
+			}
+		}
+		fmt.Println("Created workspace '" + name + "' at " + util.GetWorkspacePath(name))
+	},
+}
+
+// workspaceRemoveCmd removes a named workspace and everything in it
+var workspaceRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a workspace, including its sources, logs and artifacts",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if err := util.ValidateWorkspaceName(name); err != nil {
+			log.Fatal("Error: ", err)
+		}
+		if name == util.DefaultWorkspace {
+			log.Fatal("Error: The default workspace cannot be removed")
+		}
+		if !util.WorkspaceExists(name) {
+			log.Fatal("Error: Workspace '" + name + "' does not exist")
+		}
+		if name == util.GetWorkspace() {
+			log.Fatal("Error: Cannot remove the active workspace '" + name + "'")
+		}
+		if holder, locked := util.IsLocked(util.GetWorkspacePath(name) + "/clobber.lock"); locked {
+			log.Fatal("Error: Workspace '" + name + "' is in use by PID " + strconv.Itoa(holder.PID) + " (" + holder.Command + ")")
+		}
+
+		// Ask for confirmation, as this can't be undone
+		if !ForceRemoveWorkspace {
+			fmt.Print("Remove workspace '" + name + "' at " + util.GetWorkspacePath(name) + "? [y/N] ")
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+				fmt.Println("Aborted")
+				return
+			}
+		}
+
+		if err := os.RemoveAll(util.GetWorkspacePath(name)); err != nil {
+			log.Fatal("Error: Failed to remove workspace: ", err)
+		}
+		fmt.Println("Removed workspace '" + name + "'")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(workspaceCmd)
+	workspaceCmd.AddCommand(workspaceListCmd)
+	workspaceCmd.AddCommand(workspaceCreateCmd)
+	workspaceCmd.AddCommand(workspaceRemoveCmd)
+	workspaceRemoveCmd.Flags().BoolVarP(&ForceRemoveWorkspace, "force", "f", false, "don't ask for confirmation")
+}
@@ -2,6 +2,7 @@ package patches
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 
@@ -15,18 +16,18 @@ import (
 
 // Patch function for patching files
 func Patch(packedPatches *packr.Box, patchName string, fileToPatch string) error {
-	// Parse the necessary patch information
-	tempFilePath := "/tmp/" + patchName + ".patch"
-
-	// Create a temporary file for the patch
-	file, fileErr := os.Create(tempFilePath)
+	// Create a unique temporary file for the patch (so simultaneous runs don't collide)
+	file, fileErr := ioutil.TempFile("", "clobber-"+patchName+".*.patch")
 	if fileErr != nil {
 		return fileErr
 	}
+	tempFilePath := file.Name()
 
 	// Load the patch
 	patch, patchErr := packedPatches.FindString(patchName + ".patch")
 	if patchErr != nil {
+		file.Close()
+		os.Remove(tempFilePath)
 		return patchErr
 	}
 
@@ -0,0 +1,128 @@
+package snake
+
+// directions are the directions the snake can move in, in the order the autopilot prefers them
+var directions = []Direction{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+
+// Autopilot returns the direction the snake should go in, heading for the apple along the
+// shortest path that still leaves a way out afterwards (by following its own tail),
+// or otherwise following its tail (or heading for the most room) to stall for time
+func Autopilot(state State) Direction {
+	if state.Dead || len(state.Snake) == 0 {
+		return Direction{}
+	}
+
+	// Go for the apple, as long as the snake doesn't end up trapped by its own body
+	if path := findPath(state, state.Snake, state.Apple); path != nil {
+		body := append(append([]Position(nil), state.Snake...), path...)
+		body = body[len(body)-len(state.Snake)-1:]
+		if findPath(state, body, body[0]) != nil {
+			return state.direction(state.Snake[len(state.Snake)-1], path[0])
+		}
+	}
+
+	// Follow the tail, which keeps making room
+	if path := findPath(state, state.Snake, state.Snake[0]); path != nil {
+		return state.direction(state.Snake[len(state.Snake)-1], path[0])
+	}
+
+	// Trapped, so head wherever there's the most room left
+	head := state.Snake[len(state.Snake)-1]
+	occupied := bodyIndexes(state.Snake)
+	best, bestRoom := Direction{}, -1
+	for _, direction := range directions {
+		next, ok := state.neighbor(head, direction)
+		if !ok || state.IsWall(next) || occupied[next] > 0 {
+			continue
+		}
+		if room := state.room(next, occupied); room > bestRoom {
+			best, bestRoom = direction, room
+		}
+	}
+	return best
+}
+
+// bodyIndexes maps the positions of a snake's body to how many moves it takes
+// before they're free (the tail being the first to go)
+func bodyIndexes(body []Position) map[Position]int {
+	indexes := make(map[Position]int)
+	for i, position := range body {
+		// The snake dies if it moves to where its tail is right now, so
+		// a position is only free once the tail has moved off it
+		indexes[position] = i + 2
+	}
+	return indexes
+}
+
+// findPath returns the shortest path for the snake with the supplied body to the target (nil if
+// there's none), taking into account that the body moves out of the way as the snake moves
+func findPath(state State, body []Position, target Position) []Position {
+	head := body[len(body)-1]
+	occupied := bodyIndexes(body)
+	previous := map[Position]Position{head: head}
+	queue := []Position{head}
+	for moves := 1; len(queue) > 0; moves++ {
+		var next []Position
+		for _, position := range queue {
+			for _, direction := range directions {
+				neighbor, ok := state.neighbor(position, direction)
+				if !ok || state.IsWall(neighbor) || occupied[neighbor] > moves {
+					continue
+				}
+				if _, visited := previous[neighbor]; visited {
+					continue
+				}
+				previous[neighbor] = position
+				if neighbor == target {
+					var path []Position
+					for step := neighbor; step != head; step = previous[step] {
+						path = append([]Position{step}, path...)
+					}
+					return path
+				}
+				next = append(next, neighbor)
+			}
+		}
+		queue = next
+	}
+	return nil
+}
+
+// room returns how many positions can be reached from the supplied one
+// (ignoring that the body moves out of the way)
+func (state State) room(start Position, occupied map[Position]int) int {
+	visited := map[Position]bool{start: true}
+	queue := []Position{start}
+	for len(queue) > 0 {
+		position := queue[0]
+		queue = queue[1:]
+		for _, direction := range directions {
+			neighbor, ok := state.neighbor(position, direction)
+			if ok && !visited[neighbor] && !state.IsWall(neighbor) && occupied[neighbor] == 0 {
+				visited[neighbor] = true
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return len(visited)
+}
+
+// neighbor returns the position next to the supplied one in a direction,
+// wrapping around the edges of the level if the snake does (false if it's outside the level)
+func (state State) neighbor(position Position, direction Direction) (Position, bool) {
+	next := Position{position.X + direction.X, position.Y + direction.Y}
+	if state.Wrap {
+		next.X = (next.X + state.Size.Width) % state.Size.Width
+		next.Y = (next.Y + state.Size.Height) % state.Size.Height
+	}
+	return next, next.X >= 0 && next.Y >= 0 && next.X < state.Size.Width && next.Y < state.Size.Height
+}
+
+// direction returns the direction from a position to a neighboring one
+func (state State) direction(from Position, to Position) Direction {
+	for _, direction := range directions {
+		if next, _ := state.neighbor(from, direction); next == to {
+			return direction
+		}
+	}
+	return Direction{}
+}
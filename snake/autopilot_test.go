@@ -0,0 +1,101 @@
+package snake
+
+import (
+	"testing"
+	"time"
+)
+
+// autopilotGame lets the autopilot play a game for at most the supplied number of moves,
+// checking the rules of the game after every move (which doubles as a soak test of the engine)
+func autopilotGame(t *testing.T, size Size, mode *Mode, seed int64, maxMoves int) State {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewEngine(size, mode, seed, clock.Now)
+	state := engine.State()
+	for moves := 0; moves < maxMoves && !state.Dead; moves++ {
+		previous := state
+		engine.Step(Input{Direction: Autopilot(state)})
+		clock.Advance(engine.interval())
+		state = engine.Step(Input{})
+		checkRules(t, engine, previous, state)
+		if t.Failed() {
+			t.Fatalf("%s with seed %d broke the rules after %d moves", mode.ID(), seed, moves)
+		}
+	}
+	return state
+}
+
+// checkRules checks the state after a move
+func checkRules(t *testing.T, engine *Engine, previous State, state State) {
+	head := state.Snake[len(state.Snake)-1]
+	if state.Score < previous.Score || state.Score > previous.Score+1 {
+		t.Errorf("Score went from %d to %d in a single move", previous.Score, state.Score)
+	}
+	if state.Dead {
+		// Dying takes a wall, or the body in the way of the last move
+		next, _ := state.neighbor(head, engine.level.snakes[0].moved)
+		if !state.IsWall(head) && !engine.level.snakes[0].CheckHitbox(next) {
+			t.Errorf("Snake died for no reason at %v: %v", head, state.Snake)
+		}
+		return
+	}
+
+	body := make(map[Position]bool)
+	for i, position := range state.Snake {
+		if body[position] {
+			t.Errorf("Snake overlaps itself at %v: %v", position, state.Snake)
+		}
+		body[position] = true
+		if state.IsWall(position) {
+			t.Errorf("Snake is in a wall at %v: %v", position, state.Snake)
+		}
+		if position.X < 0 || position.Y < 0 || position.X >= state.Size.Width || position.Y >= state.Size.Height {
+			t.Errorf("Snake left the level at %v: %v", position, state.Snake)
+		}
+		if i > 0 && state.direction(state.Snake[i-1], position).Zero() {
+			t.Errorf("Snake is broken between %v and %v: %v", state.Snake[i-1], position, state.Snake)
+		}
+	}
+	if len(state.Snake) > state.Score+2 {
+		t.Errorf("Snake grew to %d with a score of %d", len(state.Snake), state.Score)
+	}
+	if body[state.Apple] || state.IsWall(state.Apple) {
+		t.Errorf("Apple is in the way at %v", state.Apple)
+	}
+}
+
+func TestAutopilot(t *testing.T) {
+	var modes []*Mode
+	for _, name := range []string{ClassicMode, WrapMode, CampaignMode} {
+		modes = append(modes, &Mode{Name: name})
+	}
+	for _, name := range PresetMapNames() {
+		modes = append(modes, &Mode{Name: ObstaclesMode, Map: presetMap(name)})
+	}
+
+	for _, mode := range modes {
+		total := 0
+		for seed := int64(1); seed <= 5; seed++ {
+			total += autopilotGame(t, Size{24, 16}, mode, seed, 1000).Score
+		}
+
+		// The autopilot should be decent at the game, not just survive
+		if average := total / 5; average < 20 {
+			t.Errorf("%s: expected the autopilot to score at least 20 on average, got %d", mode.ID(), average)
+		}
+	}
+}
+
+func TestAutopilotAvoidsTraps(t *testing.T) {
+	// The apple is at the end of a dead end that's too short for the snake to turn around in
+	engine, _ := newTestEngine(Size{10, 10})
+	for y := 1; y < 8; y++ {
+		engine.level.walls[Position{4, y}] = true
+		engine.level.walls[Position{6, y}] = true
+	}
+	engine.level.snakes[0].body = []Position{{7, 8}, {6, 8}, {5, 8}}
+	engine.level.snakes[0].length = 3
+	engine.level.apple.position = Position{5, 1}
+	if direction := Autopilot(engine.State()); direction != (Direction{-1, 0}) {
+		t.Errorf("Expected the autopilot to avoid the dead end, got %v", direction)
+	}
+}
@@ -0,0 +1,142 @@
+package snake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+
+	"github.com/Dids/clobber/util"
+	"github.com/nsf/termbox-go"
+)
+
+// Config holds the user's preferences for the game
+type Config struct {
+	// Name shown in the highscores (the user name if empty)
+	Name string `json:"name"`
+
+	// Game mode (see ModeNames), and the map of the obstacles mode (see FindMap)
+	Mode string `json:"mode"`
+	Map  string `json:"map"`
+
+	Colors Colors `json:"colors"`
+
+	// Key bindings (see Keys)
+	Keys Keys `json:"keys"`
+}
+
+// Colors of the game, using the names of the basic terminal colors
+// (default, black, red, green, yellow, blue, magenta, cyan and white)
+type Colors struct {
+	Snake      string `json:"snake"`
+	Player2    string `json:"player2"`
+	Apple      string `json:"apple"`
+	Wall       string `json:"wall"`
+	Background string `json:"background"`
+	Score      string `json:"score"`
+	Text       string `json:"text"`
+}
+
+// colorNames maps the supported color names to termbox colors
+var colorNames = map[string]termbox.Attribute{
+	"default": termbox.ColorDefault,
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+// theme is the parsed version of Colors, used for drawing
+type theme struct {
+	snake      termbox.Attribute
+	player2    termbox.Attribute
+	apple      termbox.Attribute
+	wall       termbox.Attribute
+	background termbox.Attribute
+	score      termbox.Attribute
+	text       termbox.Attribute
+}
+
+// DefaultConfig returns the configuration used when there's no config file
+func DefaultConfig() *Config {
+	return &Config{
+		Colors: Colors{
+			Snake:      "green",
+			Player2:    "yellow",
+			Apple:      "red",
+			Wall:       "white",
+			Background: "black",
+			Score:      "white",
+			Text:       "black",
+		},
+		Keys: DefaultKeys(),
+	}
+}
+
+// LoadConfig loads the configuration from a JSON file, using the
+// defaults for anything that's not set (or if the file doesn't exist)
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %s", path, err)
+	}
+	if _, err := config.theme(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %s", path, err)
+	}
+	if _, err := config.GameMode(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %s", path, err)
+	}
+	if _, err := config.Keys.bindings(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %s", path, err)
+	}
+	return config, nil
+}
+
+// GameMode returns the configured game mode, with maps looked up in the maps directory
+func (config *Config) GameMode() (*Mode, error) {
+	return NewMode(config.Mode, config.Map, util.GetSnakeMapsPath())
+}
+
+// PlayerName returns the name shown in the highscores
+func (config *Config) PlayerName() string {
+	if len(config.Name) > 0 {
+		return config.Name
+	}
+	if currentUser, err := user.Current(); err == nil && len(currentUser.Username) > 0 {
+		return currentUser.Username
+	}
+	return "Sir Hiss"
+}
+
+// theme parses the configured colors
+func (config *Config) theme() (*theme, error) {
+	var err error
+	parse := func(name string) termbox.Attribute {
+		color, ok := colorNames[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("unknown color '%s'", name)
+		}
+		return color
+	}
+	parsed := &theme{
+		snake:      parse(config.Colors.Snake),
+		player2:    parse(config.Colors.Player2),
+		apple:      parse(config.Colors.Apple),
+		wall:       parse(config.Colors.Wall),
+		background: parse(config.Colors.Background),
+		score:      parse(config.Colors.Score),
+		text:       parse(config.Colors.Text),
+	}
+	return parsed, err
+}
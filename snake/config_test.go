@@ -0,0 +1,62 @@
+package snake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clobber-snake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snake.json")
+
+	// A missing config file results in the defaults
+	config, err := LoadConfig(path)
+	if err != nil || config.Colors != DefaultConfig().Colors {
+		t.Errorf("Expected the default config, got %v (%v)", config, err)
+	}
+
+	// Anything not in the config file keeps its default
+	if err := ioutil.WriteFile(path, []byte(`{"colors": {"snake": "yellow"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err = LoadConfig(path)
+	if err != nil || config.Colors.Snake != "yellow" || config.Colors.Apple != DefaultConfig().Colors.Apple {
+		t.Errorf("Invalid config: %v (%v)", config, err)
+	}
+
+	// Unknown colors are rejected
+	if err := ioutil.WriteFile(path, []byte(`{"colors": {"wall": "pink"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("Expected an error for an unknown color")
+	}
+
+	// Keys can use a preset, but can't be used for two things
+	if err := ioutil.WriteFile(path, []byte(`{"keys": {"player1": "hjkl"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err = LoadConfig(path)
+	if err != nil || config.Keys.Player1.Left[0] != "h" || config.Keys.Pause[0] != "p" {
+		t.Errorf("Invalid keys: %v (%v)", config.Keys, err)
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"keys": {"player1": "wasd"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err = LoadConfig(path)
+	if err != nil || config.Keys.Player1.Up[0] != "w" || config.Keys.Player2.Up[0] != "up" {
+		t.Errorf("Expected player 2 to move with the arrow keys: %v (%v)", config.Keys, err)
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"keys": {"pause": ["esc"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("Expected an error for a key used for both pausing and quitting")
+	}
+}
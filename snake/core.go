@@ -1,7 +1,5 @@
 package snake
 
-import "fmt"
-
 // Position in X and Y coordinates
 type Position struct {
 	X int
@@ -10,8 +8,8 @@ type Position struct {
 
 // Size in Width and Hiehgt
 type Size struct {
-	Width  int
-	Height int
+	Width  int `json:"width"`
+	Height int `json:"height"`
 }
 
 // Direction in -1/+1 X and Y coordinates
@@ -21,9 +19,15 @@ type Direction struct {
 	// Position
 }
 
-func clearScreen() {
-	fmt.Printf("\033[3J") // Clear the history
-	fmt.Printf("\033[H")  // Clear the screen
+// isBorder returns true if the position is on the edge of an area of the supplied size
+func isBorder(size Size, pos Position) bool {
+	if pos.X == 0 || pos.Y == 0 {
+		return true
+	}
+	if pos.X == size.Width-1 || pos.Y == size.Height-1 {
+		return true
+	}
+	return false
 }
 
 // Equals compares this object with X and Y integers
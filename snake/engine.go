@@ -0,0 +1,275 @@
+package snake
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// updateIntervalMs is how often the snake moves at the start of a game
+	updateIntervalMs = 100
+
+	// minUpdateIntervalMs is how often the snake moves at most, no matter the score
+	minUpdateIntervalMs = 30
+
+	// maxCatchUpMoves is the most moves made in a single step, so the snake doesn't suddenly
+	// jump across the level after the game has been stalled (eg. by suspending the terminal)
+	maxCatchUpMoves = 5
+)
+
+// Clock returns the current time (time.Now, unless the game is being tested or replayed)
+type Clock func() time.Time
+
+// Input is what a player did since the previous step
+type Input struct {
+	// Direction the snake should turn to (zero if it should keep going)
+	Direction Direction
+
+	// Player whose snake should turn (starting at 0)
+	Player int
+}
+
+// State is a snapshot of the game after a step
+type State struct {
+	// Size of the level, including the walls
+	Size Size
+
+	// Mode of the game (see Mode.ID), and the current level of it (starting at 1)
+	Mode  string
+	Level int
+
+	// Body of the (first player's) snake (the head being the last position)
+	Snake []Position
+
+	// Position of the apple
+	Apple Position
+
+	// Score of the (first player of the) current game
+	Score int
+
+	// Game time of the last step
+	Elapsed time.Duration
+
+	// Set once the snake (or any of the snakes) has hit a wall, itself or another snake
+	Dead bool
+
+	// Every player of a multiplayer game, the first being the same as Snake and Score (nil if single player)
+	Players []PlayerState
+
+	// Whether the snake wraps around the edges of the level
+	Wrap bool
+
+	// Walls of the level, including any obstacles
+	walls map[Position]bool
+}
+
+// PlayerState is a snapshot of a player in a multiplayer game
+type PlayerState struct {
+	Snake []Position
+	Score int
+	Dead  bool
+}
+
+// Engine runs the rules of the game, without any input handling or drawing,
+// so the same seed and inputs (at the same game times) always result in the same game
+type Engine struct {
+	level  *Level
+	scores []int
+	seed   int64
+
+	// Mode of the game, and the index of its current stage
+	mode  *Mode
+	stage int
+
+	clock     Clock
+	lastClock time.Time
+
+	// Time the game has been running (skipping over stalls) and the game time of the last move
+	elapsed  time.Duration
+	lastMove time.Duration
+
+	// Inputs so far, for recording a replay
+	inputs []ReplayInput
+}
+
+// NewEngine creates a new game in the supplied mode on a level of the supplied size
+// (including the walls), placing apples with a random generator using the supplied seed
+func NewEngine(size Size, mode *Mode, seed int64, clock Clock) *Engine {
+	return NewMultiplayerEngine(size, mode, 1, seed, clock)
+}
+
+// NewMultiplayerEngine creates a new game like NewEngine, but with a snake for each of the players,
+// which ends as soon as any of the snakes dies
+func NewMultiplayerEngine(size Size, mode *Mode, players int, seed int64, clock Clock) *Engine {
+	engine := &Engine{}
+	engine.mode = mode
+	engine.scores = make([]int, players)
+	engine.level = newLevel(size, mode.stages()[0], players, rand.New(rand.NewSource(seed)))
+	engine.seed = seed
+	engine.clock = clock
+	engine.lastClock = clock()
+	return engine
+}
+
+// Step moves the snake as many times as it should have moved since the
+// previous step, then applies the input, returning the resulting state
+func (engine *Engine) Step(input Input) State {
+	// The game (and its time) is over once a snake dies
+	if engine.over() {
+		return engine.State()
+	}
+
+	// Advance the game time, skipping over any stalls
+	now := engine.clock()
+	elapsed := now.Sub(engine.lastClock)
+	if maxElapsed := maxCatchUpMoves * engine.interval(); elapsed > maxElapsed {
+		elapsed = maxElapsed
+	}
+	engine.lastClock = now
+	engine.elapsed += elapsed
+
+	// Game time only has millisecond precision, so replays can store it compactly
+	gameTime := engine.elapsed.Truncate(time.Millisecond)
+
+	// Catch up on any moves that are due, so the speed doesn't depend on how often we're called
+	for !engine.over() && gameTime-engine.lastMove >= engine.interval() {
+		engine.lastMove += engine.interval()
+		engine.move()
+	}
+
+	// The game ends with the move that killed the snake, no matter when that's noticed
+	if engine.over() {
+		engine.elapsed = engine.lastMove
+		return engine.State()
+	}
+
+	// Turning only affects the moves after the input, so only its game time matters when replaying
+	// (and only actual turns need to be recorded)
+	if !input.Direction.Zero() && input.Player >= 0 && input.Player < len(engine.level.snakes) {
+		snake := engine.level.snakes[input.Player]
+		direction := snake.direction
+		snake.UpdateDirection(input.Direction)
+		if snake.direction != direction {
+			engine.inputs = append(engine.inputs, ReplayInput{Time: gameTime, Direction: input.Direction, Player: input.Player})
+		}
+	}
+
+	return engine.State()
+}
+
+// Replay returns a recording of the game so far
+func (engine *Engine) Replay() *Replay {
+	return &Replay{
+		Seed:     engine.seed,
+		Size:     engine.level.size,
+		Mode:     engine.mode,
+		Score:    engine.scores[0],
+		Players:  len(engine.scores),
+		Duration: engine.elapsed.Truncate(time.Millisecond),
+		Inputs:   append([]ReplayInput(nil), engine.inputs...),
+	}
+}
+
+// State returns a snapshot of the game
+func (engine *Engine) State() State {
+	state := State{
+		Size:    engine.level.size,
+		Mode:    engine.mode.ID(),
+		Level:   engine.stage + 1,
+		Snake:   append([]Position(nil), engine.level.snakes[0].body...),
+		Apple:   engine.level.apple.position,
+		Score:   engine.scores[0],
+		Elapsed: engine.elapsed.Truncate(time.Millisecond),
+		Dead:    engine.over(),
+		Wrap:    engine.level.wrap,
+		walls:   engine.level.walls,
+	}
+	if len(engine.level.snakes) > 1 {
+		for i, snake := range engine.level.snakes {
+			state.Players = append(state.Players, PlayerState{
+				Snake: append([]Position(nil), snake.body...),
+				Score: engine.scores[i],
+				Dead:  snake.dead,
+			})
+		}
+	}
+	return state
+}
+
+// over returns true once any of the snakes has died
+func (engine *Engine) over() bool {
+	for _, snake := range engine.level.snakes {
+		if snake.dead {
+			return true
+		}
+	}
+	return false
+}
+
+// bestScore returns the highest score of any of the players
+func (engine *Engine) bestScore() int {
+	best := 0
+	for _, score := range engine.scores {
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// IsWall returns true if the supplied position is a wall (including obstacles) or a corner
+func (state State) IsWall(pos Position) bool {
+	return state.walls[pos]
+}
+
+// interval returns how often the snake moves, which gets faster as the score increases
+func (engine *Engine) interval() time.Duration {
+	intervalMs := updateIntervalMs - engine.bestScore()/2
+	if intervalMs < minUpdateIntervalMs {
+		intervalMs = minUpdateIntervalMs
+	}
+	return time.Duration(intervalMs) * time.Millisecond
+}
+
+// move keeps the snakes moving, eating any apple they find and dying if they hit a wall
+// (or themselves, or another snake)
+func (engine *Engine) move() {
+	level := engine.level
+	for _, snake := range level.snakes {
+		snake.Move()
+	}
+
+	// Check if colliding with a wall or another snake, then kill the snake
+	// (once all of them have moved, so moving first isn't an advantage)
+	for i, snake := range level.snakes {
+		if snake.dead {
+			continue
+		}
+		head := snake.GetHead()
+		if level.IsWall(head) {
+			snake.dead = true
+		}
+		for j, other := range level.snakes {
+			if j != i && other.CheckHitbox(head) {
+				snake.dead = true
+			}
+		}
+	}
+	if engine.over() {
+		return
+	}
+
+	// Check if a snake collides with the apple, then eat it
+	for i, snake := range level.snakes {
+		if snake.CheckHitbox(level.apple.position) {
+			level.EatApple(snake)
+			engine.scores[i]++
+		}
+	}
+
+	// Start over on the next level once the score is high enough for it
+	if stages := engine.mode.stages(); engine.stage+1 < len(stages) && engine.bestScore() >= stages[engine.stage+1].score {
+		engine.stage++
+		engine.level = newLevel(level.size, stages[engine.stage], len(level.snakes), level.random)
+	}
+}
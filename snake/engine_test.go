@@ -0,0 +1,238 @@
+package snake
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(duration time.Duration) {
+	clock.now = clock.now.Add(duration)
+}
+
+func newTestEngine(size Size) (*Engine, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	return NewEngine(size, &Mode{Name: ClassicMode}, 1, clock.Now), clock
+}
+
+func TestEngineMovement(t *testing.T) {
+	tests := []struct {
+		elapsed time.Duration
+		head    Position
+	}{
+		{0, Position{10, 10}},
+		{99 * time.Millisecond, Position{10, 10}},
+		{100 * time.Millisecond, Position{10, 11}},
+		{250 * time.Millisecond, Position{10, 12}},
+		// Catching up is limited, so a stalled game doesn't make the snake jump
+		{10 * time.Second, Position{10, 10 + maxCatchUpMoves}},
+	}
+	for _, test := range tests {
+		engine, clock := newTestEngine(Size{20, 20})
+		engine.level.apple.position = Position{1, 1}
+		clock.Advance(test.elapsed)
+		state := engine.Step(Input{})
+		if head := state.Snake[len(state.Snake)-1]; head != test.head {
+			t.Errorf("After %s: expected head at %v, got %v", test.elapsed, test.head, head)
+		}
+	}
+
+	// The speed doesn't depend on how often the engine is stepped
+	engine, clock := newTestEngine(Size{20, 20})
+	engine.level.apple.position = Position{1, 1}
+	for i := 0; i < 30; i++ {
+		clock.Advance(10 * time.Millisecond)
+		engine.Step(Input{})
+	}
+	if head := engine.State().Snake[1]; head != (Position{10, 13}) {
+		t.Errorf("Expected head at %v after 300ms of small steps, got %v", Position{10, 13}, head)
+	}
+
+	// Turning applies from the next move
+	engine, clock = newTestEngine(Size{20, 20})
+	engine.level.apple.position = Position{1, 1}
+	engine.Step(Input{Direction: Direction{1, 0}})
+	clock.Advance(100 * time.Millisecond)
+	if head := engine.Step(Input{}).Snake[1]; head != (Position{11, 10}) {
+		t.Errorf("Expected head at %v after turning right, got %v", Position{11, 10}, head)
+	}
+}
+
+func TestEngineGrowth(t *testing.T) {
+	engine, clock := newTestEngine(Size{20, 20})
+	engine.level.apple.position = Position{10, 11}
+	clock.Advance(100 * time.Millisecond)
+	state := engine.Step(Input{})
+	if state.Score != 1 {
+		t.Errorf("Expected a score of 1, got %d", state.Score)
+	}
+	if state.Apple == (Position{10, 11}) || state.IsWall(state.Apple) {
+		t.Errorf("Expected a new apple inside the level, got %v", state.Apple)
+	}
+	for _, position := range state.Snake {
+		if position == state.Apple {
+			t.Errorf("Expected the new apple not to be on the snake, got %v", state.Apple)
+		}
+	}
+
+	// The snake grows by one on the next move
+	engine.level.apple.position = Position{1, 1}
+	clock.Advance(100 * time.Millisecond)
+	if state := engine.Step(Input{}); len(state.Snake) != 3 {
+		t.Errorf("Expected the snake to grow to 3, got %v", state.Snake)
+	}
+}
+
+func TestEngineCollisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      []Position
+		direction Direction
+		dead      bool
+	}{
+		{"wall", []Position{{3, 2}, {3, 1}}, Direction{0, -1}, true},
+		{"corner", []Position{{2, 1}, {1, 1}}, Direction{-1, 0}, true},
+		{"self", []Position{{4, 4}, {5, 4}, {6, 4}, {6, 5}, {5, 5}}, Direction{0, -1}, true},
+		{"free", []Position{{3, 3}, {3, 4}}, Direction{0, 1}, false},
+	}
+	for _, test := range tests {
+		engine, clock := newTestEngine(Size{10, 10})
+		engine.level.apple.position = Position{8, 8}
+		snake := engine.level.snakes[0]
+		snake.body = test.body
+		snake.length = len(test.body)
+		snake.direction = test.direction
+		snake.moved = test.direction
+		clock.Advance(100 * time.Millisecond)
+		if state := engine.Step(Input{}); state.Dead != test.dead {
+			t.Errorf("%s: expected dead to be %t, got %t", test.name, test.dead, state.Dead)
+		}
+
+		// A dead snake stays put
+		if test.dead {
+			before := engine.State()
+			clock.Advance(time.Second)
+			if after := engine.Step(Input{}); !reflect.DeepEqual(before, after) {
+				t.Errorf("%s: expected the dead snake not to move", test.name)
+			}
+		}
+	}
+}
+
+func TestEngineSpeed(t *testing.T) {
+	tests := []struct {
+		score    int
+		interval time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+		{10, 95 * time.Millisecond},
+		{100, 50 * time.Millisecond},
+		{140, 30 * time.Millisecond},
+		{1000, 30 * time.Millisecond},
+	}
+	for _, test := range tests {
+		engine, clock := newTestEngine(Size{40, 40})
+		engine.level.apple.position = Position{1, 1}
+		engine.scores[0] = test.score
+		clock.Advance(test.interval - time.Millisecond)
+		if state := engine.Step(Input{}); state.Snake[1] != (Position{20, 20}) {
+			t.Errorf("Score %d: expected no move before %s", test.score, test.interval)
+		}
+		clock.Advance(time.Millisecond)
+		if state := engine.Step(Input{}); state.Snake[1] != (Position{20, 21}) {
+			t.Errorf("Score %d: expected a move after %s", test.score, test.interval)
+		}
+	}
+}
+
+func TestEngineDeterministic(t *testing.T) {
+	play := func() []State {
+		engine, clock := newTestEngine(Size{20, 20})
+		var states []State
+		for i := 0; i < 50; i++ {
+			clock.Advance(40 * time.Millisecond)
+			direction := Direction{}
+			if i%10 == 0 {
+				direction = Direction{1, 0}
+			} else if i%10 == 5 {
+				direction = Direction{0, 1}
+			}
+			states = append(states, engine.Step(Input{Direction: direction}))
+		}
+		return states
+	}
+	if first, second := play(), play(); !reflect.DeepEqual(first, second) {
+		t.Error("Expected the same seed, clock and inputs to result in the same game")
+	}
+}
+
+func TestMultiplayerEngine(t *testing.T) {
+	tests := []struct {
+		name       string
+		bodies     [][]Position
+		directions []Direction
+		dead       []bool
+	}{
+		{"apart", [][]Position{{{3, 3}, {3, 4}}, {{6, 3}, {6, 4}}}, []Direction{{0, 1}, {0, 1}}, []bool{false, false}},
+		{"into the other", [][]Position{{{4, 3}, {4, 4}}, {{3, 5}, {4, 5}, {5, 5}}}, []Direction{{0, 1}, {1, 0}}, []bool{true, false}},
+		{"head-on", [][]Position{{{3, 3}, {3, 4}}, {{3, 7}, {3, 6}}}, []Direction{{0, 1}, {0, -1}}, []bool{true, true}},
+	}
+	for _, test := range tests {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		engine := NewMultiplayerEngine(Size{10, 10}, &Mode{Name: ClassicMode}, 2, 1, clock.Now)
+		engine.level.apple.position = Position{8, 8}
+		for i, body := range test.bodies {
+			engine.level.snakes[i].body = body
+			engine.level.snakes[i].length = len(body)
+			engine.level.snakes[i].direction = test.directions[i]
+			engine.level.snakes[i].moved = test.directions[i]
+		}
+		clock.Advance(100 * time.Millisecond)
+		state := engine.Step(Input{})
+		for i, player := range state.Players {
+			if player.Dead != test.dead[i] {
+				t.Errorf("%s: expected player %d to be dead: %t, got %t", test.name, i+1, test.dead[i], player.Dead)
+			}
+		}
+		if state.Dead != (test.dead[0] || test.dead[1]) {
+			t.Errorf("%s: expected the game to be over once a snake dies", test.name)
+		}
+	}
+
+	// Each player scores their own apples
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewMultiplayerEngine(Size{20, 20}, &Mode{Name: ClassicMode}, 2, 1, clock.Now)
+	head := engine.level.snakes[1].GetHead()
+	engine.level.apple.position = Position{head.X, head.Y + 1}
+	clock.Advance(100 * time.Millisecond)
+	if state := engine.Step(Input{}); state.Score != 0 || state.Players[1].Score != 1 {
+		t.Errorf("Expected only the second player to score, got %v", state.Players)
+	}
+}
+
+func TestRoundWinner(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []PlayerState
+		winner  int
+	}{
+		{"last one standing", []PlayerState{{Score: 5, Dead: true}, {Score: 1}}, 1},
+		{"both dead", []PlayerState{{Score: 5, Dead: true}, {Score: 1, Dead: true}}, 0},
+		{"draw", []PlayerState{{Score: 3, Dead: true}, {Score: 3, Dead: true}}, -1},
+	}
+	for _, test := range tests {
+		if winner := roundWinner(test.players); winner != test.winner {
+			t.Errorf("%s: expected player %d to win, got %d", test.name, test.winner, winner)
+		}
+	}
+}
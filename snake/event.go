@@ -8,46 +8,38 @@ import (
 
 // ListenEvents will start listening for keyboard and resize events,
 // then emit them on the supplied channels
-func ListenEvents(game *Game, inputEvent chan termbox.Key, resizeEvent chan Size) {
+func ListenEvents(game *Game, inputEvent chan termbox.Event, resizeEvent chan Size) {
 	termbox.SetInputMode(termbox.InputEsc)
 	for {
-		if game.done {
+		select {
+		case <-game.exit:
 			//log.Print("Game done, stopping input handler")
 			return
+		default:
 		}
 		switch ev := termbox.PollEvent(); ev.Type {
 		case termbox.EventKey:
-			log.Println("Termbox key event:", ev.Key)
-			if ev.Key == termbox.KeyEsc || ev.Key == termbox.KeyCtrlC {
-				//log.Println("ESC or CTRL-C pressed, stopping input handler")
-				game.done = true
-				game.exit <- true
+			// NOTE: Logging would end up on top of the game
+			// log.Println("Termbox key event:", ev.Key)
+			if game.bindings.isQuit(ev) {
+				//log.Println("Quit key or CTRL-C pressed, stopping input handler")
+				game.Quit()
+				return
+			}
+			select {
+			case inputEvent <- ev:
+			case <-game.exit:
 				return
 			}
-			inputEvent <- ev.Key
 		case termbox.EventResize:
 			//log.Println("Termbox resize event:", ev)
-			resizeEvent <- Size{ev.Width, ev.Height}
+			select {
+			case resizeEvent <- Size{ev.Width, ev.Height}:
+			case <-game.exit:
+				return
+			}
 		case termbox.EventError:
 			log.Fatal(ev.Err)
 		}
 	}
 }
-
-// GetInputDirection will convert a keyboard key to a Direction
-func GetInputDirection(key termbox.Key) Direction {
-	// log.Println("Get direction from input:", key)
-
-	switch key {
-	case termbox.KeyArrowLeft:
-		return Direction{-1, 0}
-	case termbox.KeyArrowDown:
-		return Direction{0, 1}
-	case termbox.KeyArrowRight:
-		return Direction{+1, 0}
-	case termbox.KeyArrowUp:
-		return Direction{0, -1}
-	default:
-		return Direction{0, 0}
-	}
-}
@@ -3,43 +3,154 @@ package snake
 import (
 	"fmt"
 	"log"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Dids/clobber/util"
 	"github.com/nsf/termbox-go"
-	"golang.org/x/crypto/ssh/terminal"
 )
 
 const millisecondsPerFrame = 16 // 16ms == 60fps
 
+// autopilotRestartDelay is how long the result of a game played by the autopilot is shown
+const autopilotRestartDelay = 3 * time.Second
+
+// minLevelSize is the smallest level that can be played in
+var minLevelSize = Size{12, 8}
+
 // Game of sneaky snakey goodness
 type Game struct {
-	level *Level
+	// Rules of the current game (nil if the terminal is too small to play, or when replaying)
+	engine *Engine
+
+	// Replay being played back (nil when playing), and how fast
+	replayer *replayer
+	speed    float64
+
+	// State of the current game after the latest step
+	state State
+
+	// Mode of the game
+	mode *Mode
+
+	// Highscore table of the mode (best first), and the name the player's scores are added under
+	highscores []Highscore
+	playerName string
+
+	// Whether the current game has been recorded (as a replay and in the highscores)
+	recorded bool
+
+	// Shown once the snake dies, until a new game is started
+	gameOver *gameOver
+
+	// Number of players, how many rounds a match is the best of, and how many rounds each player has won
+	players int
+	rounds  int
+	wins    []int
+
+	// Whether the autopilot plays new games, whether it's playing the current one, and whether it
+	// has played any of the current game (which keeps the game out of the highscores and replays,
+	// like multiplayer games)
+	autopilot    bool
+	autopiloting bool
+	assisted     bool
 
-	// Score information for the current game
-	score *Score
+	// Whether the game is paused (and since when, and for how long in total, which the
+	// clock of the engine leaves out), and whether the help is shown (which pauses it too)
+	paused    bool
+	pausedAt  time.Time
+	pausedFor time.Duration
+	help      bool
 
 	// Size of the terminal
 	size Size
 
-	// Done status of the game
-	done bool
+	// Closed when the game should exit
+	exit     chan bool
+	exitOnce sync.Once
 
-	exit chan bool
+	// Progress (nil if not building) and result (nil until finished) of the Clover build
+	statusMutex sync.Mutex
+	status      *BuildStatus
+	result      *buildResult
 
-	input  chan termbox.Key
+	// Closed once the update loop has stopped drawing
+	updateDone chan bool
+
+	// Colors used for drawing
+	theme *theme
+
+	// Keys used for playing
+	bindings *bindings
+
+	// Start time of the previous frame (when replaying)
+	lastFrame time.Time
+
+	input  chan termbox.Event
 	resize chan Size
 }
 
-// NewGame starts a new game of Snake (bet you didn't guess that!)
-func NewGame() *Game {
+// gameOver is the result of a finished game
+type gameOver struct {
+	// Rank of the game in the highscores (0 if it didn't make it)
+	rank int
+
+	// When the game ended
+	ended time.Time
+
+	// Player who won the round of a multiplayer game (-1 if it's a draw),
+	// and whether that won them the match
+	winner    int
+	matchOver bool
+}
+
+// NewGame starts a new game of Snake (bet you didn't guess that!),
+// using the supplied configuration (see LoadConfig)
+func NewGame(config *Config) *Game {
 	game := &Game{}
 
-	game.score = NewScore()
-	game.done = false
+	theme, err := config.theme()
+	if err != nil {
+		log.Fatal("Invalid config:", err)
+	}
+	game.theme = theme
+
+	bindings, err := config.Keys.bindings()
+	if err != nil {
+		log.Fatal("Invalid config:", err)
+	}
+	game.bindings = bindings
+
+	mode, err := config.GameMode()
+	if err != nil {
+		log.Fatal("Invalid config:", err)
+	}
+	game.mode = mode
+
+	// Scores from before the highscore table are moved into it, and a broken table is replaced
+	// (none of which is worth ending the game over, let alone the build it's played during)
+	game.playerName = config.PlayerName()
+	if brokenPath, err := RecoverHighscores(util.GetHighscoresPath()); err != nil {
+		log.Println("Warning: Failed to move the broken highscore table aside:", err)
+	} else if len(brokenPath) > 0 {
+		log.Println("Warning: The highscore table was broken, so it was moved to " + brokenPath + " and a new one was started")
+	}
+	if err := MigrateScoreFile(util.GetScorePath(), util.GetHighscoresPath(), game.playerName); err != nil {
+		log.Println("Warning: Failed to migrate the old highscore:", err)
+	}
+	highscores, err := LoadHighscores(util.GetHighscoresPath())
+	if err != nil {
+		log.Println("Warning: Failed to load the highscores:", err)
+	}
+	game.highscores = HighscoresForMode(highscores, mode.ID())
+
+	game.players = 1
 	game.exit = make(chan bool)
-	game.input = make(chan termbox.Key)
+	game.updateDone = make(chan bool)
+	game.input = make(chan termbox.Event)
 	game.resize = make(chan Size)
 
 	// // Add support for graceful shutdown with CTRL-C
@@ -51,14 +162,29 @@ func NewGame() *Game {
 	// 	game.exit <- true
 	// }()
 
-	game.Resize()
+	return game
+}
 
+// NewReplayGame plays back a replay at the supplied speed (1 being the original speed)
+func NewReplayGame(config *Config, replay *Replay, speed float64) *Game {
+	game := NewGame(config)
+	game.replayer = newReplayer(replay)
+	game.state = game.replayer.state
+	game.speed = speed
 	return game
 }
 
-// IncrementScore will increase the score of the current game
-func (game *Game) IncrementScore() {
-	game.score.IncrementScore()
+// EnableTwoPlayers makes the game a match between two players (arrow keys vs. WASD, by default)
+// of the best of the supplied number of rounds
+func (game *Game) EnableTwoPlayers(rounds int) {
+	game.players = 2
+	game.rounds = rounds
+	game.wins = make([]int, game.players)
+}
+
+// EnableAutopilot lets the autopilot play (see Autopilot), until a direction key is pressed
+func (game *Game) EnableAutopilot() {
+	game.autopilot = true
 }
 
 // Start will run the update loop in a goroutine
@@ -69,121 +195,483 @@ func (game *Game) Start() {
 	}
 	defer termbox.Close()
 
+	// Fit the level to the terminal
+	game.Resize()
+
 	// Start listening for events
 	go ListenEvents(game, game.input, game.resize)
 
 	go game.update()
 
-	// Block until an exit signal is received
+	// Block until an exit signal is received, then wait for the last frame to be drawn
 	<-game.exit
+	<-game.updateDone
+
+	// Record the game that was being played
+	game.record()
+}
+
+// Quit ends the game, which makes Start return
+func (game *Game) Quit() {
+	game.exitOnce.Do(func() {
+		close(game.exit)
+	})
 }
 
 // Restart the game
 func (game *Game) Restart() {
-	// Reset the game score
-	game.score = NewScore()
-
 	// Recreate the level
-	game.level = NewLevel(game, game.level.size)
+	game.newEngine(game.state.Size)
+}
 
-	// Clear the terminal screen to avoid artefacting
-	clearScreen()
+// newEngine starts a new game on a level of the supplied size, recording the previous game
+func (game *Game) newEngine(size Size) {
+	game.record()
+	game.engine = NewMultiplayerEngine(size, game.mode, game.players, time.Now().UnixNano(), game.now)
+	game.state = game.engine.State()
+	game.recorded = false
+	game.gameOver = nil
+	game.autopiloting = game.autopilot
+	game.assisted = false
 }
 
-// Resize adjusts the size of the game and level
-func (game *Game) Resize() {
-	// Get the terminal size
-	terminalWidth, terminalHeight, err := terminal.GetSize(0)
+// record saves a replay of the current game and adds it to the highscores (if it scored anything),
+// returning its rank in the highscores (0 if it didn't make it)
+func (game *Game) record() int {
+	if game.engine == nil || game.recorded || game.assisted || game.players > 1 || game.state.Score == 0 {
+		return 0
+	}
+	game.recorded = true
+
+	// Losing a replay or a highscore isn't worth ending the game (or the build) over
+	replay := game.engine.Replay()
+	replay.Recorded = time.Now()
+	entry := Highscore{
+		Name:     game.playerName,
+		Score:    game.state.Score,
+		Date:     replay.Recorded,
+		Duration: game.state.Elapsed,
+		Mode:     game.state.Mode,
+		Revision: game.revision(),
+	}
+	if err := replay.Save(util.GetReplaysPath()); err == nil {
+		entry.Replay = filepath.Base(replay.Path)
+	}
+	highscores, rank, err := AddHighscore(util.GetHighscoresPath(), entry)
 	if err != nil {
-		log.Fatal(err)
+		return 0
 	}
 
-	game.size = Size{terminalWidth, terminalHeight}
+	// Only prune once the new entry is in the table, so its replay is kept along with the others in it
+	if allHighscores, err := LoadHighscores(util.GetHighscoresPath()); err == nil {
+		PruneReplays(util.GetReplaysPath(), maxReplays, allHighscores)
+	}
+	game.highscores = highscores
+	return rank
+}
 
-	levelWidth := game.size.Width
-	levelHeight := game.size.Height
+// Resize adjusts the size of the game and level to the terminal,
+// leaving the level empty if the terminal is too small to play
+func (game *Game) Resize() {
+	// Get the terminal size
+	terminalWidth, terminalHeight := termbox.Size()
+	game.size = Size{terminalWidth, terminalHeight}
 
-	// Calculate the width and height of the game, as well as taking into account power of two
-	levelWidth /= 2
-	if levelWidth%2 != 0 {
-		levelWidth--
-	}
-	if levelHeight%2 != 0 {
-		levelHeight--
+	// Replays are played back on the level size they were recorded on
+	if game.replayer != nil {
+		return
 	}
 
-	// TODO: This is likely a rounding issue of some sort?
-	// Adjust height for UI elements
-	// NOTE: Hyper works with -1, but Terminal requires -2
-	levelHeight--
-	levelHeight--
+	// Each level coordinate is two columns wide, so it's roughly square
+	levelWidth := game.size.Width / 2
 
-	// Adjust height for Clover build process at the bottom
-	levelHeight--
+	// Adjust height for the score at the top and the Clover build process at the bottom
+	levelHeight := game.size.Height - 2
+
+	if levelWidth < minLevelSize.Width || levelHeight < minLevelSize.Height {
+		game.engine = nil
+		return
+	}
 
 	// Update the level size
-	game.level = NewLevel(game, Size{levelWidth, levelHeight})
+	game.newEngine(Size{levelWidth, levelHeight})
 }
 
 // Update loop for the game
 func (game *Game) update() {
+	defer close(game.updateDone)
 	for {
 		select {
-		case key := <-game.input:
-			direction := GetInputDirection(key)
-			if !direction.Zero() {
-				game.level.snake.UpdateDirection(direction)
-			}
+		case event := <-game.input:
+			game.handleKey(event)
 		case <-game.resize:
 			// Terminal resize event received, update accordingly
 			game.Resize()
-		default:
+		case <-game.exit:
 			// Quit the game if it's done
-			if game.done {
-				//log.Println("Game done, skipping update")
-				return
-			}
-
+			return
+		default:
 			// Keep track of the frame start time
 			frameStartTime := time.Now()
 
-			// Clear the terminal screen
-			clearScreen()
-
-			// Update the level
-			game.level.Update()
-
-			// Draw the scores
-			scoreString := "SCORE: " + strconv.Itoa(game.score.GetScore())
-			if game.score.GetHighscore() > 0 {
-				scoreString += " (HIGHSCORE: " + strconv.Itoa(game.score.GetHighscore()) + ")"
-			}
-			scoreString = CenterAlignString(scoreString, (game.level.size.Width*2)-len(scoreString))
-			scoreStringLength := len(scoreString)
-			paddedScoreString := scoreString
-			if scoreStringLength < (game.level.size.Width * 2) {
-				for i := 0; i < (game.level.size.Width*2)-scoreStringLength; i++ {
-					paddedScoreString += " "
+			// Keep things moving (unless paused)
+			if game.paused {
+				// Nothing moves
+			} else if game.replayer != nil {
+				if !game.lastFrame.IsZero() {
+					game.state = game.replayer.advance(time.Duration(float64(frameStartTime.Sub(game.lastFrame)) * game.speed))
 				}
+				game.lastFrame = frameStartTime
+			} else if game.engine != nil && game.gameOver == nil {
+				game.step(Input{})
+
+				// The autopilot steers once the snake has moved, so it never steers based on an old position
+				if game.autopiloting && game.gameOver == nil {
+					game.assisted = true
+					if direction := Autopilot(game.state); !direction.Zero() {
+						game.step(Input{Direction: direction})
+					}
+				}
+			} else if game.autopiloting && game.gameOver != nil && time.Since(game.gameOver.ended) >= autopilotRestartDelay {
+				game.Restart()
 			}
-			scoreString = "\033[47;30m" + paddedScoreString + "\033[0m"
-			fmt.Println(scoreString)
 
-			// Draw the level
-			fmt.Println(game.level.Render())
+			// Draw the next frame into the back buffer, then show it
+			game.draw()
+			termbox.Flush()
 
 			// Artificially delay the event loop to constrain the frames per second
 			time.Sleep(frameStartTime.Add(time.Millisecond * millisecondsPerFrame).Sub(time.Now()))
+		}
+	}
+}
 
-			// Fix resizing artefacts by flushing terminal back buffer
-			termbox.Flush()
+// handleKey handles a key press (other than quitting, which ListenEvents takes care of)
+func (game *Game) handleKey(event termbox.Event) {
+	key := bindingFor(event)
+	switch {
+	case game.bindings.help[key]:
+		game.help = !game.help
+		game.setPaused(game.help)
+	case game.bindings.pause[key] && game.help:
+		// Closing the help with the pause key keeps the game paused
+		game.help = false
+	case game.bindings.pause[key]:
+		game.setPaused(!game.paused)
+	case game.help:
+		// Any other key closes the help
+		game.help = false
+		game.setPaused(false)
+	case game.bindings.restart[key]:
+		game.setPaused(false)
+		if game.replayer != nil {
+			game.replayer = newReplayer(game.replayer.replay)
+			game.state = game.replayer.state
+			game.lastFrame = time.Time{}
+		} else if game.engine != nil {
+			game.restartMatch()
+		}
+	case game.gameOver != nil:
+		if event.Key == termbox.KeyEnter || event.Key == termbox.KeySpace {
+			game.restartMatch()
+		}
+	case game.paused:
+		// The snake stays put until the game is resumed
+	default:
+		input := game.bindings.input(event, game.players)
+		if !input.Direction.Zero() && game.engine != nil && game.replayer == nil {
+			// Taking over from the autopilot turns it off for good
+			game.autopilot = false
+			game.autopiloting = false
+			game.step(input)
 		}
 	}
 }
 
+// restartMatch starts a new game (or the next round of a multiplayer match,
+// or a new match once it's over)
+func (game *Game) restartMatch() {
+	if game.gameOver != nil && game.gameOver.matchOver {
+		game.wins = make([]int, game.players)
+	}
+	game.Restart()
+}
+
+// setPaused pauses or resumes the game
+func (game *Game) setPaused(paused bool) {
+	if paused == game.paused {
+		return
+	}
+	game.paused = paused
+	if paused {
+		game.pausedAt = time.Now()
+	} else {
+		game.pausedFor += time.Since(game.pausedAt)
+		game.lastFrame = time.Time{}
+	}
+}
+
+// now is the clock of the engine, which stands still while the game is paused
+func (game *Game) now() time.Time {
+	if game.paused {
+		return game.pausedAt.Add(-game.pausedFor)
+	}
+	return time.Now().Add(-game.pausedFor)
+}
+
+// step advances the game, recording it and showing the highscores (or the result
+// of the round, in a multiplayer game) once a snake dies
+func (game *Game) step(input Input) {
+	game.state = game.engine.Step(input)
+	if !game.state.Dead {
+		return
+	}
+	game.gameOver = &gameOver{rank: game.record(), ended: time.Now(), winner: -1}
+	if len(game.state.Players) > 0 {
+		game.gameOver.winner = roundWinner(game.state.Players)
+		if game.gameOver.winner >= 0 {
+			game.wins[game.gameOver.winner]++
+			game.gameOver.matchOver = game.wins[game.gameOver.winner] > game.rounds/2
+		}
+	}
+}
+
+// roundWinner returns the player who won a round of a multiplayer game (-1 if it's a draw),
+// which is the last one standing, or the one with the highest score if they died at the same time
+func roundWinner(players []PlayerState) int {
+	var candidates []int
+	for i, player := range players {
+		if !player.Dead {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		for i := range players {
+			candidates = append(candidates, i)
+		}
+	}
+	winner, best, tied := -1, -1, false
+	for _, i := range candidates {
+		switch {
+		case players[i].Score > best:
+			winner, best, tied = i, players[i].Score, false
+		case players[i].Score == best:
+			tied = true
+		}
+	}
+	if tied {
+		return -1
+	}
+	return winner
+}
+
+// draw draws the score, level and build status into the termbox back buffer
+func (game *Game) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+
+	if (game.engine == nil && game.replayer == nil) || !game.fits() {
+		needed := minLevelSize
+		if game.replayer != nil {
+			needed = game.replayer.replay.Size
+		}
+		message := fmt.Sprintf("Terminal too small (%dx%d needed)", needed.Width*2, needed.Height+2)
+		drawText(0, game.size.Height/2, game.size.Width, CenterAlignString(message, game.size.Width-len(message)), termbox.ColorDefault, termbox.ColorDefault)
+		status, color := game.statusRow()
+		drawText(0, game.size.Height-1, game.size.Width, status, termbox.ColorWhite, color)
+		return
+	}
+	width := game.state.Size.Width * 2
+
+	// Draw the scores
+	scoreString := "SCORE: " + strconv.Itoa(game.state.Score)
+	if players := game.state.Players; len(players) > 1 {
+		scoreString = fmt.Sprintf("PLAYER 1: %d  PLAYER 2: %d", players[0].Score, players[1].Score)
+		if len(game.wins) > 1 {
+			scoreString += fmt.Sprintf("  (ROUNDS %d-%d, BEST OF %d)", game.wins[0], game.wins[1], game.rounds)
+		}
+	}
+	if game.state.Mode != ClassicMode {
+		mode := strings.ToUpper(game.state.Mode)
+		if game.state.Mode == CampaignMode {
+			mode += " LEVEL " + strconv.Itoa(game.state.Level)
+		}
+		scoreString = mode + "  " + scoreString
+	}
+	if game.replayer != nil {
+		scoreString = "REPLAY " + scoreString
+	} else if game.autopiloting {
+		scoreString = "AUTOPILOT " + scoreString
+	} else if highscore := game.highscore(); highscore > 0 && game.players == 1 {
+		scoreString += " (HIGHSCORE: " + strconv.Itoa(highscore) + ")"
+	}
+	scoreString = CenterAlignString(scoreString, width-len(scoreString))
+	drawText(0, 0, width, scoreString, game.theme.text, game.theme.score)
+
+	// Draw the level, with the help, the pause or the highscores (or the result of the round) on top
+	drawLevel(0, 1, game.state, game.theme)
+	if game.help {
+		game.drawHelp(1, width, game.state.Size.Height)
+	} else if game.paused {
+		lines := []string{"PAUSED", "", fmt.Sprintf("Press %s to resume", describeKeys(game.bindings.keys.Pause))}
+		game.drawOverlay(1, width, game.state.Size.Height, lines, 0)
+	} else if game.gameOver != nil && game.players > 1 {
+		game.drawRoundResult(1, width, game.state.Size.Height)
+	} else if game.gameOver != nil {
+		game.drawHighscores(1, width, game.state.Size.Height)
+	}
+
+	// Draw the build progress in the row reserved for it
+	status, color := game.statusRow()
+	drawText(0, game.state.Size.Height+1, width, status, termbox.ColorWhite, color)
+}
+
+// highscore returns the best score so far, including the current game
+func (game *Game) highscore() int {
+	highscore := game.state.Score
+	if len(game.highscores) > 0 && game.highscores[0].Score > highscore {
+		highscore = game.highscores[0].Score
+	}
+	return highscore
+}
+
+// drawHighscores draws the highscore table in the middle of the supplied rows,
+// highlighting the game that just ended (if it made it to the table)
+func (game *Game) drawHighscores(offsetY int, width int, height int) {
+	lines := []string{"GAME OVER", ""}
+	if game.gameOver.rank > 0 {
+		lines[1] = fmt.Sprintf("New highscore, rank #%d!", game.gameOver.rank)
+	}
+	lines = append(lines, "")
+	for i, entry := range game.highscores {
+		lines = append(lines, fmt.Sprintf("%2d. %-12.12s %5d  %s", i+1, entry.Name, entry.Score, entry.Date.Format("2006-01-02")))
+	}
+	lines = append(lines, "", fmt.Sprintf("Press ENTER to play again, %s to exit", describeKeys(game.bindings.keys.Quit)))
+	highlight := -1
+	if game.gameOver.rank > 0 {
+		highlight = game.gameOver.rank + 2
+	}
+	game.drawOverlay(offsetY, width, height, lines, highlight)
+}
+
+// drawRoundResult draws the winner of the round (and of the match, if it's over)
+// in the middle of the supplied rows
+func (game *Game) drawRoundResult(offsetY int, width int, height int) {
+	result := "DRAW"
+	if winner := game.gameOver.winner; winner >= 0 && game.gameOver.matchOver {
+		result = fmt.Sprintf("PLAYER %d WINS THE MATCH", winner+1)
+	} else if winner >= 0 {
+		result = fmt.Sprintf("PLAYER %d WINS THE ROUND", winner+1)
+	}
+	lines := []string{result, "", fmt.Sprintf("Rounds won: %d-%d (best of %d)", game.wins[0], game.wins[1], game.rounds), ""}
+	quit := describeKeys(game.bindings.keys.Quit)
+	if game.gameOver.matchOver {
+		lines = append(lines, fmt.Sprintf("Press ENTER for a new match, %s to exit", quit))
+	} else {
+		lines = append(lines, fmt.Sprintf("Press ENTER for the next round, %s to exit", quit))
+	}
+	game.drawOverlay(offsetY, width, height, lines, 0)
+}
+
+// drawHelp draws the keys in the middle of the supplied rows
+func (game *Game) drawHelp(offsetY int, width int, height int) {
+	keys := game.bindings.keys
+	describe := func(names []string) string {
+		return strings.ToUpper(strings.Join(names, ", "))
+	}
+	lines := []string{"HELP", ""}
+	if game.players > 1 {
+		lines = append(lines,
+			"Player 1 moves with "+describeDirections(keys.Player1),
+			"Player 2 moves with "+describeDirections(keys.Player2))
+	} else {
+		lines = append(lines, fmt.Sprintf("Move with %s or %s", describeDirections(keys.Player1), describeDirections(keys.Player2)))
+	}
+	lines = append(lines,
+		"Pause: "+describe(keys.Pause),
+		"Restart: "+describe(keys.Restart),
+		"Help: "+describe(keys.Help),
+		"Exit: "+describe(keys.Quit)+", CTRL-C",
+		"",
+		"Press any key to continue")
+	game.drawOverlay(offsetY, width, height, lines, 0)
+}
+
+// drawOverlay draws lines of text in the middle of the supplied rows, highlighting one of them
+// (unless it's negative), and cutting them short (but keeping the last line) if they don't fit
+func (game *Game) drawOverlay(offsetY int, width int, height int, lines []string, highlight int) {
+	if len(lines) > height {
+		lines = append(lines[:height-1], lines[len(lines)-1])
+	}
+	top := offsetY + (height-len(lines))/2
+	for i, line := range lines {
+		fg, bg := game.theme.text, game.theme.score
+		if i == highlight && i < len(lines)-1 {
+			fg, bg = game.theme.score, game.theme.snake
+		}
+		drawText(0, top+i, width, CenterAlignString(line, width-len([]rune(line))), fg, bg)
+	}
+}
+
+// fits returns true if the level (plus the score and status rows) fits the terminal
+func (game *Game) fits() bool {
+	return game.state.Size.Width*2 <= game.size.Width && game.state.Size.Height+2 <= game.size.Height
+}
+
+// drawLevel draws the walls, snakes and apple at the supplied offset,
+// with each coordinate taking up two columns
+func drawLevel(offsetX int, offsetY int, state State, theme *theme) {
+	snakes := make(map[Position]termbox.Attribute)
+	for _, position := range state.Snake {
+		snakes[position] = theme.snake
+	}
+	for i := 1; i < len(state.Players); i++ {
+		for _, position := range state.Players[i].Snake {
+			snakes[position] = theme.player2
+		}
+	}
+	for y := 0; y < state.Size.Height; y++ {
+		for x := 0; x < state.Size.Width; x++ {
+			color := theme.background
+			if snake, ok := snakes[Position{x, y}]; ok {
+				color = snake
+			} else if state.Apple.Equals(x, y) {
+				color = theme.apple
+			} else if state.IsWall(Position{x, y}) {
+				color = theme.wall
+			}
+			setCell(offsetX+x*2, offsetY+y, ' ', color, color)
+			setCell(offsetX+x*2+1, offsetY+y, ' ', color, color)
+		}
+	}
+}
+
+// drawText draws text padded (or cut off) to the given width, clipped to the terminal
+func drawText(x int, y int, width int, text string, fg termbox.Attribute, bg termbox.Attribute) {
+	runes := []rune(text)
+	for column := 0; column < width; column++ {
+		r := ' '
+		if column < len(runes) {
+			r = runes[column]
+		}
+		setCell(x+column, y, r, fg, bg)
+	}
+}
+
+// setCell sets a cell in the termbox back buffer, unless it's outside the terminal
+func setCell(x int, y int, r rune, fg termbox.Attribute, bg termbox.Attribute) {
+	width, height := termbox.Size()
+	if x < 0 || y < 0 || x >= width || y >= height {
+		return
+	}
+	termbox.SetCell(x, y, r, fg, bg)
+}
+
 // CenterAlignString will align the input string in
 // the middle, based on the supplied width
 func CenterAlignString(input string, width int) string {
 	divider := width / 2
+	if divider < 0 {
+		divider = 0
+	}
 	return strings.Repeat(" ", divider) + input + strings.Repeat(" ", divider)
 }
@@ -0,0 +1,287 @@
+package snake
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Keys are the key bindings of the game, each being a list of key names, which are either
+// a single character (eg. "w" or "?") or one of the names in keyNames (eg. "up" or "space")
+type Keys struct {
+	// Movement of the player (or of each player, in a two player game)
+	Player1 Directions `json:"player1"`
+	Player2 Directions `json:"player2"`
+
+	Pause   []string `json:"pause"`
+	Restart []string `json:"restart"`
+	Help    []string `json:"help"`
+
+	// Quitting (CTRL-C always quits, so there's no way to get stuck in the game)
+	Quit []string `json:"quit"`
+}
+
+// Directions are the keys for moving a snake, which can also be
+// configured with the name of one of the presets (eg. "hjkl")
+type Directions struct {
+	Up    []string `json:"up"`
+	Down  []string `json:"down"`
+	Left  []string `json:"left"`
+	Right []string `json:"right"`
+}
+
+// directionPresets are the common ways of moving around
+var directionPresets = map[string]Directions{
+	"arrows": {Up: []string{"up"}, Down: []string{"down"}, Left: []string{"left"}, Right: []string{"right"}},
+	"wasd":   {Up: []string{"w"}, Down: []string{"s"}, Left: []string{"a"}, Right: []string{"d"}},
+	"hjkl":   {Up: []string{"k"}, Down: []string{"j"}, Left: []string{"h"}, Right: []string{"l"}},
+}
+
+// keyNames are the names of the keys that aren't characters
+var keyNames = map[string]termbox.Key{
+	"up":        termbox.KeyArrowUp,
+	"down":      termbox.KeyArrowDown,
+	"left":      termbox.KeyArrowLeft,
+	"right":     termbox.KeyArrowRight,
+	"enter":     termbox.KeyEnter,
+	"space":     termbox.KeySpace,
+	"tab":       termbox.KeyTab,
+	"backspace": termbox.KeyBackspace2,
+	"esc":       termbox.KeyEsc,
+}
+
+// DefaultKeys returns the key bindings used when none are configured
+func DefaultKeys() Keys {
+	return Keys{
+		Player1: directionPresets["arrows"].copy(),
+		Player2: directionPresets["wasd"].copy(),
+		Pause:   []string{"p"},
+		Restart: []string{"r"},
+		Help:    []string{"?"},
+		Quit:    []string{"esc"},
+	}
+}
+
+// UnmarshalJSON loads the keys, with a player that isn't configured making way for the other one
+// (eg. moving with the arrow keys when the other player is configured to use WASD)
+func (keys *Keys) UnmarshalJSON(data []byte) error {
+	type plainKeys Keys
+	if err := json.Unmarshal(data, (*plainKeys)(keys)); err != nil {
+		return err
+	}
+	var configured map[string]json.RawMessage
+	if err := json.Unmarshal(data, &configured); err != nil {
+		return err
+	}
+	_, player1 := configured["player1"]
+	_, player2 := configured["player2"]
+	defaults := DefaultKeys()
+	if player1 && !player2 {
+		keys.Player2 = keys.Player2.avoiding(keys.Player1, defaults.Player1)
+	} else if player2 && !player1 {
+		keys.Player1 = keys.Player1.avoiding(keys.Player2, defaults.Player2)
+	}
+	return nil
+}
+
+// UnmarshalJSON loads the keys either as an object, or as the name of a preset
+func (directions *Directions) UnmarshalJSON(data []byte) error {
+	var preset string
+	if err := json.Unmarshal(data, &preset); err == nil {
+		presetDirections, ok := directionPresets[preset]
+		if !ok {
+			return fmt.Errorf("unknown keys '%s' (the presets are arrows, wasd and hjkl)", preset)
+		}
+		*directions = presetDirections.copy()
+		return nil
+	}
+
+	// Only replace the directions that are set
+	type plainDirections Directions
+	return json.Unmarshal(data, (*plainDirections)(directions))
+}
+
+// copy returns a copy of the directions, so unmarshaling into it leaves the presets alone
+func (directions Directions) copy() Directions {
+	clone := func(names []string) []string {
+		return append([]string(nil), names...)
+	}
+	return Directions{Up: clone(directions.Up), Down: clone(directions.Down), Left: clone(directions.Left), Right: clone(directions.Right)}
+}
+
+// avoiding returns the directions if none of their keys are taken, else the alternative if none
+// of its keys are, or else the directions without the keys that are taken
+func (directions Directions) avoiding(taken Directions, alternative Directions) Directions {
+	takenKeys := taken.keys()
+	overlaps := func(directions Directions) bool {
+		for key := range directions.keys() {
+			if takenKeys[key] {
+				return true
+			}
+		}
+		return false
+	}
+	if !overlaps(directions) {
+		return directions
+	}
+	if !overlaps(alternative) {
+		return alternative.copy()
+	}
+	free := func(names []string) []string {
+		var freeNames []string
+		for _, name := range names {
+			if binding, err := parseKey(name); err != nil || !takenKeys[binding] {
+				freeNames = append(freeNames, name)
+			}
+		}
+		return freeNames
+	}
+	return Directions{Up: free(directions.Up), Down: free(directions.Down), Left: free(directions.Left), Right: free(directions.Right)}
+}
+
+// keys returns the keys of all the directions (leaving out any unknown ones, which bindings reports)
+func (directions Directions) keys() map[keyBinding]bool {
+	keys := make(map[keyBinding]bool)
+	for _, names := range [][]string{directions.Up, directions.Down, directions.Left, directions.Right} {
+		for _, name := range names {
+			if binding, err := parseKey(name); err == nil {
+				keys[binding] = true
+			}
+		}
+	}
+	return keys
+}
+
+// keyBinding is a key that can be pressed, being either a special key or a character
+type keyBinding struct {
+	key termbox.Key
+	ch  rune
+}
+
+// bindingFor returns the binding of a key event, ignoring the case of characters
+func bindingFor(event termbox.Event) keyBinding {
+	if event.Ch != 0 {
+		return keyBinding{ch: unicode.ToLower(event.Ch)}
+	}
+	return keyBinding{key: event.Key}
+}
+
+// parseKey parses the name of a key
+func parseKey(name string) (keyBinding, error) {
+	if key, ok := keyNames[strings.ToLower(name)]; ok {
+		return keyBinding{key: key}, nil
+	}
+	if runes := []rune(name); len(runes) == 1 && !unicode.IsSpace(runes[0]) {
+		return keyBinding{ch: unicode.ToLower(runes[0])}, nil
+	}
+	return keyBinding{}, fmt.Errorf("unknown key '%s'", name)
+}
+
+// bindings is the parsed version of Keys, used for handling key presses
+type bindings struct {
+	directions []map[keyBinding]Direction
+	pause      map[keyBinding]bool
+	restart    map[keyBinding]bool
+	help       map[keyBinding]bool
+	quit       map[keyBinding]bool
+
+	// The keys as configured, for showing them
+	keys Keys
+}
+
+// bindings parses the key bindings, making sure no key is used for two things
+func (keys Keys) bindings() (*bindings, error) {
+	parsed := &bindings{keys: keys}
+	used := make(map[keyBinding]string)
+	parse := func(names []string, action string) (map[keyBinding]bool, error) {
+		set := make(map[keyBinding]bool)
+		for _, name := range names {
+			binding, err := parseKey(name)
+			if err != nil {
+				return nil, err
+			}
+			if other, ok := used[binding]; ok && other != action {
+				return nil, fmt.Errorf("key '%s' is used for both %s and %s", name, other, action)
+			}
+			used[binding] = action
+			set[binding] = true
+		}
+		return set, nil
+	}
+
+	for player, directions := range []Directions{keys.Player1, keys.Player2} {
+		parsedDirections := make(map[keyBinding]Direction)
+		for _, binding := range []struct {
+			names     []string
+			direction Direction
+			action    string
+		}{
+			{directions.Up, Direction{0, -1}, "up"},
+			{directions.Down, Direction{0, 1}, "down"},
+			{directions.Left, Direction{-1, 0}, "left"},
+			{directions.Right, Direction{1, 0}, "right"},
+		} {
+			set, err := parse(binding.names, fmt.Sprintf("player %d %s", player+1, binding.action))
+			if err != nil {
+				return nil, err
+			}
+			for key := range set {
+				parsedDirections[key] = binding.direction
+			}
+		}
+		parsed.directions = append(parsed.directions, parsedDirections)
+	}
+
+	var err error
+	if parsed.pause, err = parse(keys.Pause, "pause"); err != nil {
+		return nil, err
+	}
+	if parsed.restart, err = parse(keys.Restart, "restart"); err != nil {
+		return nil, err
+	}
+	if parsed.help, err = parse(keys.Help, "help"); err != nil {
+		return nil, err
+	}
+	if parsed.quit, err = parse(keys.Quit, "quit"); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// input returns the movement of a key press, with the keys of both players
+// moving the only player in a single player game
+func (bindings *bindings) input(event termbox.Event, players int) Input {
+	for player, directions := range bindings.directions {
+		if direction, ok := directions[bindingFor(event)]; ok {
+			if player >= players {
+				player = players - 1
+			}
+			return Input{Direction: direction, Player: player}
+		}
+	}
+	return Input{}
+}
+
+// isQuit returns true if the key press should exit the game
+func (bindings *bindings) isQuit(event termbox.Event) bool {
+	return event.Key == termbox.KeyCtrlC || bindings.quit[bindingFor(event)]
+}
+
+// describeKeys returns a short description of the first of the supplied keys (eg. "ESC" or "P")
+func describeKeys(names []string) string {
+	if len(names) == 0 {
+		return "(none)"
+	}
+	return strings.ToUpper(names[0])
+}
+
+// describeDirections returns a short description of the movement keys (eg. "W/A/S/D" or "the arrow keys")
+func describeDirections(directions Directions) string {
+	if len(directions.Up) > 0 && directions.Up[0] == "up" {
+		return "the arrow keys"
+	}
+	return strings.Join([]string{describeKeys(directions.Up), describeKeys(directions.Left), describeKeys(directions.Down), describeKeys(directions.Right)}, "/")
+}
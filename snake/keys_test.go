@@ -0,0 +1,121 @@
+package snake
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestKeysUnmarshal(t *testing.T) {
+	// Presets and single directions can be mixed, and anything left out keeps its default
+	keys := DefaultKeys()
+	if err := json.Unmarshal([]byte(`{"player1": "hjkl", "player2": {"up": ["i"]}, "pause": ["space"]}`), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if keys.Player1.Up[0] != "k" || keys.Player1.Left[0] != "h" {
+		t.Errorf("Expected player 1 to use hjkl, got %v", keys.Player1)
+	}
+	if keys.Player2.Up[0] != "i" || keys.Player2.Down[0] != "s" {
+		t.Errorf("Expected player 2 to only change up, got %v", keys.Player2)
+	}
+	if keys.Pause[0] != "space" || keys.Quit[0] != "esc" {
+		t.Errorf("Expected only pause to change, got %v", keys)
+	}
+
+	if err := json.Unmarshal([]byte(`{"player1": "zqsd"}`), &keys); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
+
+func TestKeysUnmarshalPresets(t *testing.T) {
+	// A player that isn't configured moves with the keys the other one doesn't use
+	tests := []struct {
+		json    string
+		player1 string
+		player2 string
+		valid   bool
+	}{
+		{`{"player1": "wasd"}`, "w", "up", true},
+		{`{"player2": "arrows"}`, "w", "up", true},
+		{`{"player1": "hjkl"}`, "k", "w", true},
+		{`{"player2": "hjkl"}`, "up", "k", true},
+		{`{"player1": {"up": ["w"]}}`, "w", "", true},
+		{`{"player1": "wasd", "player2": "wasd"}`, "w", "w", false},
+	}
+	for _, test := range tests {
+		keys := DefaultKeys()
+		if err := json.Unmarshal([]byte(test.json), &keys); err != nil {
+			t.Fatal(err)
+		}
+		up := func(directions Directions) string {
+			if len(directions.Up) == 0 {
+				return ""
+			}
+			return directions.Up[0]
+		}
+		if up(keys.Player1) != test.player1 || up(keys.Player2) != test.player2 {
+			t.Errorf("%s: expected up to be %q and %q, got %v and %v", test.json, test.player1, test.player2, keys.Player1, keys.Player2)
+		}
+		if _, err := keys.bindings(); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid to be %v, got %v", test.json, test.valid, err)
+		}
+	}
+}
+
+func TestKeyBindings(t *testing.T) {
+	tests := []struct {
+		keys  func(keys *Keys)
+		valid bool
+	}{
+		{func(keys *Keys) {}, true},
+		{func(keys *Keys) { keys.Player1 = directionPresets["hjkl"] }, true},
+		{func(keys *Keys) { keys.Pause = []string{"P", "space"} }, true},
+		{func(keys *Keys) { keys.Pause = []string{"w"} }, false},
+		{func(keys *Keys) { keys.Restart = []string{"esc"} }, false},
+		{func(keys *Keys) { keys.Player2 = directionPresets["arrows"] }, false},
+		{func(keys *Keys) { keys.Player1.Down = []string{"up"} }, false},
+		{func(keys *Keys) { keys.Help = []string{"shift"} }, false},
+	}
+	for i, test := range tests {
+		keys := DefaultKeys()
+		test.keys(&keys)
+		if _, err := keys.bindings(); (err == nil) != test.valid {
+			t.Errorf("Test %d: expected valid to be %v, got %v", i, test.valid, err)
+		}
+	}
+}
+
+func TestKeyInput(t *testing.T) {
+	keys := DefaultKeys()
+	keys.Player1 = directionPresets["hjkl"]
+	bindings, err := keys.bindings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		event   termbox.Event
+		players int
+		input   Input
+	}{
+		{termbox.Event{Ch: 'h'}, 2, Input{Direction: Direction{-1, 0}}},
+		{termbox.Event{Ch: 'J'}, 2, Input{Direction: Direction{0, 1}}},
+		{termbox.Event{Ch: 'w'}, 2, Input{Direction: Direction{0, -1}, Player: 1}},
+		{termbox.Event{Ch: 'w'}, 1, Input{Direction: Direction{0, -1}}},
+		{termbox.Event{Key: termbox.KeyArrowUp}, 1, Input{}},
+		{termbox.Event{Ch: 'p'}, 1, Input{}},
+	}
+	for _, test := range tests {
+		if input := bindings.input(test.event, test.players); input != test.input {
+			t.Errorf("%v with %d players: expected %v, got %v", test.event, test.players, test.input, input)
+		}
+	}
+
+	if !bindings.isQuit(termbox.Event{Key: termbox.KeyEsc}) || !bindings.isQuit(termbox.Event{Key: termbox.KeyCtrlC}) {
+		t.Error("Expected ESC and CTRL-C to quit")
+	}
+	if !bindings.pause[bindingFor(termbox.Event{Ch: 'P'})] || !bindings.help[bindingFor(termbox.Event{Ch: '?'})] {
+		t.Error("Expected P to pause and ? to show the help")
+	}
+}
@@ -2,134 +2,143 @@ package snake
 
 import (
 	"math/rand"
-	"time"
 )
 
-const updateIntervalMs = 100
-
 // Level of awesomeness?
 type Level struct {
-	// Game is the current game instance
-	game *Game
-
 	// Size of the level
 	size Size
 
-	lastUpdate time.Time
+	// Source of randomness for placing apples
+	random *rand.Rand
+
+	// Walls of the level, and whether the snake wraps around its edges
+	walls map[Position]bool
+	wrap  bool
+
+	// Snake of each player
+	snakes []*Snake
 
-	snake *Snake
 	apple *Apple
 }
 
 // NewLevel creates a new level of a certain size,
 // also spawning in the snake and an apple
-func NewLevel(game *Game, size Size) *Level {
+func NewLevel(size Size, random *rand.Rand) *Level {
+	return newLevel(size, stage{}, 1, random)
+}
+
+// newLevel creates a level of a certain size with the walls of the supplied stage,
+// spawning in a snake for each player
+func newLevel(size Size, stage stage, players int, random *rand.Rand) *Level {
 	// log.Println("Creating a new level of size", size)
 
 	level := &Level{}
-	level.game = game
 	level.size = size
+	level.random = random
+	level.wrap = stage.wrap
+
+	// Create the snakes next to each other, at roughly the center of the level
+	var spawns []Position
+	for player := 0; player < players; player++ {
+		spawn := Position{level.size.Width * (player + 1) / (players + 1), level.size.Height / 2}
+		spawns = append(spawns, spawn)
+		snake := NewSnake(spawn, Direction{0, 1})
+		if level.wrap {
+			snake.wrap = size
+		}
+		level.snakes = append(level.snakes, snake)
+	}
 
-	level.lastUpdate = time.Now()
-
-	// Create a snake at roughly the center of the level
-	level.snake = NewSnake(Position{level.size.Width / 2, level.size.Height / 2}, Direction{0, 1})
+	// Obstacles are kept clear of the snakes and the first few moves they make
+	level.walls = make(map[Position]bool)
+	if stage.obstacles != nil {
+		for position := range stage.obstacles.obstacles(size) {
+			if !nearSpawn(position, spawns) {
+				level.walls[position] = true
+			}
+		}
+	}
+	if !level.wrap {
+		for y := 0; y < size.Height; y++ {
+			for x := 0; x < size.Width; x++ {
+				if isBorder(size, Position{x, y}) {
+					level.walls[Position{x, y}] = true
+				}
+			}
+		}
+	}
 
 	// Create the initial apple
-	level.apple = &Apple{level.GetRandomPosition()}
+	level.apple = NewApple(level.GetRandomPosition())
 
 	return level
 }
 
-// EatApple will destroy the current apple, increment the snake size and score,
-// finally spawning a new apple
-func (level *Level) EatApple() {
+// nearSpawn returns true if the position is in the way of a freshly spawned snake
+func nearSpawn(position Position, spawns []Position) bool {
+	for _, spawn := range spawns {
+		if position.X >= spawn.X-1 && position.X <= spawn.X+1 && position.Y >= spawn.Y-2 && position.Y <= spawn.Y+4 {
+			return true
+		}
+	}
+	return false
+}
+
+// EatApple will destroy the current apple and increment
+// the size of the snake that ate it, finally spawning a new apple
+func (level *Level) EatApple(snake *Snake) {
 	// log.Println("Apple was eaten")
 
 	// Increment the size of the snake
-	level.snake.IncrementSize()
-
-	// Increment the game score
-	level.game.score.IncrementScore()
+	snake.IncrementSize()
 
 	// Move the apple to a new location
 	level.apple.position = level.GetRandomPosition()
 }
 
-// GetRandomPosition will return a randomize Position,
-// constrained to the size of the current level
+// GetRandomPosition will return a randomize Position inside the walls,
+// constrained to the size of the current level and avoiding the snake
 func (level *Level) GetRandomPosition() Position {
-	x := rand.Intn(level.size.Width-2) + 1
-	y := rand.Intn(level.size.Height-2) + 1
-	// log.Println("Generated a random position at", Position{x, y})
-	return Position{x, y}
-}
-
-// Render returns a string representation of the level
-func (level *Level) Render() string {
-	result := ""
-
-	for y := 0; y < level.size.Height; y++ {
-		for x := 0; x < level.size.Width; x++ {
-			if level.snake.CheckHitbox(Position{x, y}) {
-				result += "\033[42m  \033[0m"
-			} else if level.apple.position.Equals(x, y) {
-				result += "\033[41m  \033[0m"
-			} else if level.IsWall((Position{x, y})) {
-				result += "\033[47m  \033[0m"
-			} else {
-				result += "\033[40m  \033[0m"
-			}
+	// Without a border, the whole level is fair game
+	border := 1
+	if level.wrap {
+		border = 0
+	}
+	for i := 0; i < 100; i++ {
+		x := level.random.Intn(level.size.Width-border*2) + border
+		y := level.random.Intn(level.size.Height-border*2) + border
+		// log.Println("Generated a random position at", Position{x, y})
+		if !level.isTaken(Position{x, y}) {
+			return Position{x, y}
 		}
+	}
 
-		if y < level.size.Height-1 {
-			result += "\n"
+	// The snake is taking up most of the level, so just use the first free position
+	for y := border; y < level.size.Height-border; y++ {
+		for x := border; x < level.size.Width-border; x++ {
+			if !level.isTaken(Position{x, y}) {
+				return Position{x, y}
+			}
 		}
 	}
-
-	return result
+	return Position{1, 1}
 }
 
-// IsWall will return true if the supplied position is a wall or a corner
-func (level *Level) IsWall(pos Position) bool {
-	if pos.X == 0 || pos.Y == 0 {
+// isTaken returns true if the supplied position is a wall or part of a snake
+func (level *Level) isTaken(pos Position) bool {
+	if level.IsWall(pos) {
 		return true
 	}
-	if pos.X == level.size.Width-1 || pos.Y == level.size.Height-1 {
-		return true
+	for _, snake := range level.snakes {
+		if snake.CheckHitbox(pos) {
+			return true
+		}
 	}
 	return false
 }
 
-// TODO: Should we run the update loop in a separate thread?
-//       If we do that, do we need to sync the threads with mutexes?
-
-// Update keeps things moving
-func (level *Level) Update() {
-	// Constrain the snake movement to run much slower than the actual game loop,
-	// but also increase the snake speed over time, based on the score
-	if level.lastUpdate.Add(time.Millisecond*time.Duration(updateIntervalMs-(level.game.score.GetScore()/2))).Sub(time.Now()) <= 0 {
-		// Keep moving the snake
-		level.snake.Move()
-
-		// Update the last update timestamp
-		level.lastUpdate = time.Now()
-	}
-
-	// Keep checking if the snake collides with the apple,
-	// then call EatApple() if it does
-	if level.snake.CheckHitbox(level.apple.position) {
-		level.EatApple()
-	}
-
-	// Check if colliding with a wall, then kill the snake
-	if level.IsWall(level.snake.GetHead()) {
-		level.snake.dead = true
-	}
-
-	// Check if the snake collides with itself, then start over
-	if level.snake.dead {
-		level.game.Restart()
-		return
-	}
+// IsWall will return true if the supplied position is a wall (including obstacles) or a corner
+func (level *Level) IsWall(pos Position) bool {
+	return level.walls[pos]
 }
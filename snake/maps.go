@@ -0,0 +1,118 @@
+package snake
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Map is a layout of obstacles, stored as plain text where '#' is a wall and anything else is empty.
+// Maps are stretched to fit the level, so the same map works in any terminal size.
+type Map struct {
+	Name string   `json:"name"`
+	Rows []string `json:"rows"`
+}
+
+// presetMaps are the maps that are always available
+var presetMaps = map[string]string{
+	"pillars": `
+................
+................
+...##......##...
+...##......##...
+................
+................
+...##......##...
+...##......##...
+................
+................`,
+	"brackets": `
+................
+................
+.#####....#####.
+.#............#.
+.#............#.
+................
+................
+.#............#.
+.#####....#####.
+................`,
+	"tunnel": `
+################
+................
+................
+................
+................
+................
+................
+................
+################`,
+}
+
+// PresetMapNames returns the names of the built-in maps, sorted
+func PresetMapNames() []string {
+	var names []string
+	for name := range presetMaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseMap parses the text of a map
+func ParseMap(name string, text string) (*Map, error) {
+	gameMap := &Map{Name: name}
+	walls := 0
+	for _, row := range strings.Split(strings.TrimSpace(strings.Replace(text, "\r", "", -1)), "\n") {
+		gameMap.Rows = append(gameMap.Rows, row)
+		walls += strings.Count(row, "#")
+	}
+	if walls == 0 {
+		return nil, fmt.Errorf("map %s has no walls", name)
+	}
+	return gameMap, nil
+}
+
+// FindMap loads a built-in map by name, or a map file either by path or
+// by its name in the supplied directory (with or without the .txt extension)
+func FindMap(name string, dir string) (*Map, error) {
+	if text, ok := presetMaps[name]; ok {
+		return ParseMap(name, text)
+	}
+	for _, path := range []string{name, filepath.Join(dir, name), filepath.Join(dir, name+".txt")} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return ParseMap(strings.TrimSuffix(filepath.Base(path), ".txt"), string(data))
+	}
+	return nil, fmt.Errorf("no map named '%s' (the built-in maps are %s)", name, strings.Join(PresetMapNames(), ", "))
+}
+
+// obstacles returns the walls of the map, stretched to a level of the supplied size
+func (gameMap *Map) obstacles(size Size) map[Position]bool {
+	width := 0
+	for _, row := range gameMap.Rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	height := len(gameMap.Rows)
+
+	walls := make(map[Position]bool)
+	for y := 0; y < size.Height; y++ {
+		row := gameMap.Rows[y*height/size.Height]
+		for x := 0; x < size.Width; x++ {
+			if column := x * width / size.Width; column < len(row) && row[column] == '#' {
+				walls[Position{x, y}] = true
+			}
+		}
+	}
+	return walls
+}
@@ -0,0 +1,123 @@
+package snake
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ClassicMode is the game as it's always been, with walls around the level
+	ClassicMode = "classic"
+
+	// WrapMode has no walls, so the snake comes back on the other side
+	WrapMode = "wrap"
+
+	// ObstaclesMode adds the walls of a map to the level
+	ObstaclesMode = "obstacles"
+
+	// CampaignMode advances through the campaign levels as the score increases
+	CampaignMode = "campaign"
+)
+
+// defaultMap is the map used in the obstacles mode if none is chosen
+const defaultMap = "pillars"
+
+// ModeNames lists the game modes
+var ModeNames = []string{ClassicMode, WrapMode, ObstaclesMode, CampaignMode}
+
+// Mode is a way of playing the game
+type Mode struct {
+	Name string `json:"name"`
+
+	// Map of the obstacles mode (nil in the other modes)
+	Map *Map `json:"map,omitempty"`
+}
+
+// stage is a level of a mode, which is reached once the score is high enough
+type stage struct {
+	score     int
+	wrap      bool
+	obstacles *Map
+}
+
+// campaign is the levels of the campaign mode, in order
+var campaign = []stage{
+	{score: 0},
+	{score: 5, obstacles: presetMap("pillars")},
+	{score: 10, wrap: true},
+	{score: 20, obstacles: presetMap("brackets")},
+	{score: 30, wrap: true, obstacles: presetMap("tunnel")},
+	{score: 45, wrap: true, obstacles: presetMap("pillars")},
+}
+
+// NewMode returns the mode with the supplied name (the classic mode if empty, or the
+// obstacles mode if there's a map), loading the map of the obstacles mode (see FindMap)
+func NewMode(name string, mapName string, mapsDir string) (*Mode, error) {
+	if len(mapName) > 0 {
+		if len(name) == 0 {
+			name = ObstaclesMode
+		} else if name != ObstaclesMode {
+			return nil, fmt.Errorf("maps are only used in the %s mode", ObstaclesMode)
+		}
+	}
+	switch name {
+	case "":
+		return &Mode{Name: ClassicMode}, nil
+	case ClassicMode, WrapMode, CampaignMode:
+		return &Mode{Name: name}, nil
+	case ObstaclesMode:
+		if len(mapName) == 0 {
+			mapName = defaultMap
+		}
+		gameMap, err := FindMap(mapName, mapsDir)
+		if err != nil {
+			return nil, err
+		}
+		return &Mode{Name: name, Map: gameMap}, nil
+	}
+	return nil, fmt.Errorf("unknown mode '%s' (the modes are %s)", name, strings.Join(ModeNames, ", "))
+}
+
+// validate checks a mode that wasn't created with NewMode (eg. one loaded from a replay)
+func (mode *Mode) validate() error {
+	switch mode.Name {
+	case ClassicMode, WrapMode, CampaignMode:
+		return nil
+	case ObstaclesMode:
+		if mode.Map == nil || len(mode.Map.Rows) == 0 {
+			return fmt.Errorf("the obstacles mode needs a map")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown mode '%s'", mode.Name)
+}
+
+// ID identifies the mode (and map) in the highscores, eg. "obstacles:pillars"
+func (mode *Mode) ID() string {
+	if mode.Map != nil {
+		return mode.Name + ":" + mode.Map.Name
+	}
+	return mode.Name
+}
+
+// stages returns the levels of the mode, in order
+func (mode *Mode) stages() []stage {
+	switch mode.Name {
+	case WrapMode:
+		return []stage{{wrap: true}}
+	case ObstaclesMode:
+		return []stage{{obstacles: mode.Map}}
+	case CampaignMode:
+		return campaign
+	}
+	return []stage{{}}
+}
+
+// presetMap returns a built-in map
+func presetMap(name string) *Map {
+	gameMap, err := ParseMap(name, presetMaps[name])
+	if err != nil {
+		panic(err)
+	}
+	return gameMap
+}
@@ -0,0 +1,134 @@
+package snake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clobber-maps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "custom.txt"), []byte("....\n.##.\n....\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty.txt"), []byte("....\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		mapName string
+		id      string
+	}{
+		{"", "", ClassicMode},
+		{WrapMode, "", WrapMode},
+		{ObstaclesMode, "", "obstacles:pillars"},
+		{"", "tunnel", "obstacles:tunnel"},
+		{ObstaclesMode, "custom", "obstacles:custom"},
+		{ObstaclesMode, filepath.Join(dir, "custom.txt"), "obstacles:custom"},
+		{CampaignMode, "", CampaignMode},
+		{"unknown", "", ""},
+		{WrapMode, "pillars", ""},
+		{ObstaclesMode, "missing", ""},
+		{ObstaclesMode, "empty", ""},
+	}
+	for _, test := range tests {
+		mode, err := NewMode(test.name, test.mapName, dir)
+		if len(test.id) == 0 {
+			if err == nil {
+				t.Errorf("Mode '%s' with map '%s': expected an error", test.name, test.mapName)
+			}
+		} else if err != nil || mode.ID() != test.id {
+			t.Errorf("Mode '%s' with map '%s': expected %s, got %v (%v)", test.name, test.mapName, test.id, mode, err)
+		}
+	}
+}
+
+func TestMapObstacles(t *testing.T) {
+	gameMap, err := ParseMap("test", "#..\n...\n..#")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each map cell is stretched to cover 2x2 cells of the level
+	walls := gameMap.obstacles(Size{6, 6})
+	for _, position := range []Position{{0, 0}, {1, 1}, {4, 4}, {5, 5}} {
+		if !walls[position] {
+			t.Errorf("Expected a wall at %v", position)
+		}
+	}
+	if len(walls) != 8 {
+		t.Errorf("Expected 8 walls, got %v", walls)
+	}
+}
+
+func TestObstacleLevel(t *testing.T) {
+	mode, err := NewMode(ObstaclesMode, "pillars", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		engine := NewEngine(Size{32, 20}, mode, seed, time.Now)
+		state := engine.State()
+		if !state.IsWall(Position{0, 0}) || !state.IsWall(Position{6, 4}) {
+			t.Fatal("Expected the border and the pillars to be walls")
+		}
+		if state.IsWall(state.Apple) {
+			t.Errorf("Seed %d: expected the apple not to be in a wall, got %v", seed, state.Apple)
+		}
+		for _, position := range state.Snake {
+			if state.IsWall(position) {
+				t.Errorf("Seed %d: expected the snake not to be in a wall, got %v", seed, state.Snake)
+			}
+		}
+	}
+}
+
+func TestWrapMode(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewEngine(Size{10, 10}, &Mode{Name: WrapMode}, 1, clock.Now)
+	engine.level.apple.position = Position{1, 1}
+	snake := engine.level.snakes[0]
+	snake.body = []Position{{5, 8}, {5, 9}}
+
+	// Leaving the level at the bottom comes back at the top
+	clock.Advance(100 * time.Millisecond)
+	state := engine.Step(Input{})
+	if state.Dead || state.Snake[1] != (Position{5, 0}) {
+		t.Errorf("Expected the snake to wrap around to %v, got %v", Position{5, 0}, state.Snake)
+	}
+}
+
+func TestCampaignMode(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewEngine(Size{32, 20}, &Mode{Name: CampaignMode}, 1, clock.Now)
+	for level := 1; level <= len(campaign); level++ {
+		state := engine.State()
+		if state.Level != level {
+			t.Fatalf("Expected level %d at a score of %d, got %d", level, state.Score, state.Level)
+		}
+		if state.IsWall(Position{0, 10}) == campaign[level-1].wrap {
+			t.Errorf("Level %d: expected the border to be a wall only if not wrapping around", level)
+		}
+
+		// Feed the snake until it reaches the next level, keeping the score
+		for engine.stage == level-1 && level < len(campaign) {
+			engine.level.snakes[0].body = []Position{{16, 9}, {16, 10}}
+			engine.level.snakes[0].length = 2
+			engine.level.apple.position = Position{16, 11}
+			clock.Advance(engine.interval())
+			if state := engine.Step(Input{}); state.Dead {
+				t.Fatalf("Level %d: expected the snake to survive, got %v", level, state)
+			}
+		}
+	}
+	if state := engine.State(); state.Score != campaign[len(campaign)-1].score {
+		t.Errorf("Expected a score of %d on the last level, got %d", campaign[len(campaign)-1].score, state.Score)
+	}
+}
@@ -0,0 +1,251 @@
+package snake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Dids/clobber/util"
+)
+
+// maxReplays is the number of replays kept, keeping the best games
+// (besides the replays of the games in the highscore table of any mode)
+const maxReplays = 20
+
+// replayStepDuration is the longest step taken when playing back a replay,
+// which keeps it well below the time the engine considers a stall
+const replayStepDuration = 10 * time.Millisecond
+
+// Replay is a recording of a game, which can be played back exactly as it happened
+type Replay struct {
+	// When the game was played
+	Recorded time.Time `json:"recorded"`
+
+	// Seed of the random generator placing the apples
+	Seed int64 `json:"seed"`
+
+	// Size of the level, including the walls
+	Size Size `json:"size"`
+
+	// Mode of the game (the classic mode if missing, as in replays from before there were modes)
+	Mode *Mode `json:"mode,omitempty"`
+
+	// Number of players (a single player if missing)
+	Players int `json:"players,omitempty"`
+
+	// Final score of the game
+	Score int `json:"score"`
+
+	// Game time when the recording ends
+	Duration time.Duration `json:"duration"`
+
+	// Every direction change, in order
+	Inputs []ReplayInput `json:"inputs"`
+
+	// Path of the replay file (if loaded from or saved to one)
+	Path string `json:"-"`
+}
+
+// ReplayInput is a direction change of a player at a certain game time, stored as
+// [milliseconds, x, y] (with the player added for any but the first) to keep replays compact
+type ReplayInput struct {
+	Time      time.Duration
+	Direction Direction
+	Player    int
+}
+
+// MarshalJSON stores the input as [milliseconds, x, y] or [milliseconds, x, y, player]
+func (input ReplayInput) MarshalJSON() ([]byte, error) {
+	values := []int64{int64(input.Time / time.Millisecond), int64(input.Direction.X), int64(input.Direction.Y)}
+	if input.Player > 0 {
+		values = append(values, int64(input.Player))
+	}
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON loads an input stored as [milliseconds, x, y] or [milliseconds, x, y, player]
+func (input *ReplayInput) UnmarshalJSON(data []byte) error {
+	var values []int64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if len(values) != 3 && len(values) != 4 {
+		return fmt.Errorf("invalid input %s", data)
+	}
+	input.Time = time.Duration(values[0]) * time.Millisecond
+	input.Direction = Direction{int(values[1]), int(values[2])}
+	if len(values) == 4 {
+		input.Player = int(values[3])
+	}
+	return nil
+}
+
+// LoadReplay loads a replay from a file
+func LoadReplay(path string) (*Replay, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	replay := &Replay{}
+	if err := json.Unmarshal(data, replay); err != nil {
+		return nil, fmt.Errorf("invalid replay %s: %s", path, err)
+	}
+	if replay.Size.Width < 3 || replay.Size.Height < 3 {
+		return nil, fmt.Errorf("invalid replay %s: level too small", path)
+	}
+	if replay.Mode != nil {
+		if err := replay.Mode.validate(); err != nil {
+			return nil, fmt.Errorf("invalid replay %s: %s", path, err)
+		}
+	}
+	replay.Path = path
+	return replay, nil
+}
+
+// Save writes the replay to a new file in the supplied directory, named after when it was recorded
+// (with a number added if another game was recorded at the same time)
+func (replay *Replay) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(replay)
+	if err != nil {
+		return err
+	}
+	name := replay.Recorded.Format("20060102-150405.000")
+	path := filepath.Join(dir, name+".json")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.json", name, i))
+	}
+	if err := util.WriteFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	replay.Path = path
+	return nil
+}
+
+// ListReplays loads all replays in the supplied directory, best first
+// (ignoring any files that aren't replays)
+func ListReplays(dir string) ([]*Replay, error) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var replays []*Replay
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() || !strings.HasSuffix(fileInfo.Name(), ".json") {
+			continue
+		}
+		if replay, err := LoadReplay(filepath.Join(dir, fileInfo.Name())); err == nil {
+			replays = append(replays, replay)
+		}
+	}
+	sort.SliceStable(replays, func(i, j int) bool {
+		if replays[i].Score != replays[j].Score {
+			return replays[i].Score > replays[j].Score
+		}
+		return replays[i].Recorded.After(replays[j].Recorded)
+	})
+	return replays, nil
+}
+
+// PruneReplays removes all but the best replays in the supplied directory,
+// along with the replays of the highscores (which may be in a mode with lower scores)
+func PruneReplays(dir string, keep int, highscores []Highscore) error {
+	replays, err := ListReplays(dir)
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool)
+	for _, entry := range highscores {
+		if len(entry.Replay) > 0 {
+			referenced[entry.Replay] = true
+		}
+	}
+	for i := keep; i < len(replays); i++ {
+		if referenced[filepath.Base(replays[i].Path)] {
+			continue
+		}
+		if err := os.Remove(replays[i].Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayer plays back a replay by stepping an engine through the recorded inputs
+type replayer struct {
+	replay *Replay
+	engine *Engine
+
+	// Time of the fake clock driving the engine, and the game time played back so far
+	now     time.Time
+	elapsed time.Duration
+
+	// Index of the next input
+	next int
+
+	state State
+}
+
+// newReplayer prepares a replay for playing back
+func newReplayer(replay *Replay) *replayer {
+	player := &replayer{replay: replay, now: time.Unix(0, 0)}
+	mode := replay.Mode
+	if mode == nil {
+		mode = &Mode{Name: ClassicMode}
+	}
+	players := replay.Players
+	if players < 1 {
+		players = 1
+	}
+	player.engine = NewMultiplayerEngine(replay.Size, mode, players, replay.Seed, func() time.Time { return player.now })
+	player.state = player.engine.State()
+	return player
+}
+
+// advance plays back the supplied amount of game time, returning the resulting state
+func (player *replayer) advance(duration time.Duration) State {
+	target := player.elapsed + duration
+	if target > player.replay.Duration {
+		target = player.replay.Duration
+	}
+	for player.next < len(player.replay.Inputs) && player.replay.Inputs[player.next].Time <= target {
+		input := player.replay.Inputs[player.next]
+		player.stepTo(input.Time)
+		player.state = player.engine.Step(Input{Direction: input.Direction, Player: input.Player})
+		player.next++
+	}
+	player.stepTo(target)
+	return player.state
+}
+
+// stepTo steps the engine up to the supplied game time, in steps short enough to never count as a stall
+func (player *replayer) stepTo(target time.Duration) {
+	for player.elapsed < target {
+		step := target - player.elapsed
+		if step > replayStepDuration {
+			step = replayStepDuration
+		}
+		player.now = player.now.Add(step)
+		player.elapsed += step
+		player.state = player.engine.Step(Input{})
+	}
+}
+
+// finished returns true once the whole replay has been played back
+func (player *replayer) finished() bool {
+	return player.elapsed >= player.replay.Duration || player.state.Dead
+}
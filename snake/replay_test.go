@@ -0,0 +1,138 @@
+package snake
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// playRecordedGame plays a game chasing apples with irregular frame times
+// (including a stall), returning its replay and final state
+func playRecordedGame() (*Replay, State) {
+	engine, clock := newTestEngine(Size{20, 15})
+	state := engine.State()
+	for i := 0; i < 2000 && !state.Dead; i++ {
+		clock.Advance(time.Duration(7+i*13%23) * time.Millisecond)
+		if i == 120 {
+			clock.Advance(3 * time.Second)
+		}
+
+		// Head for the apple, which eventually runs the snake into itself (or a wall)
+		head := state.Snake[len(state.Snake)-1]
+		input := Input{}
+		switch {
+		case state.Apple.X < head.X:
+			input.Direction = Direction{-1, 0}
+		case state.Apple.X > head.X:
+			input.Direction = Direction{1, 0}
+		case state.Apple.Y < head.Y:
+			input.Direction = Direction{0, -1}
+		case state.Apple.Y > head.Y:
+			input.Direction = Direction{0, 1}
+		}
+		state = engine.Step(input)
+	}
+	return engine.Replay(), state
+}
+
+func TestReplayPlayback(t *testing.T) {
+	replay, expected := playRecordedGame()
+	if len(replay.Inputs) == 0 || replay.Duration == 0 || expected.Score == 0 {
+		t.Fatalf("Expected a recording, got %v", replay)
+	}
+
+	// Playing back gives the same result, no matter the speed
+	for _, frame := range []time.Duration{time.Millisecond, 16 * time.Millisecond, 160 * time.Millisecond, time.Hour} {
+		player := newReplayer(replay)
+		var state State
+		for !player.finished() {
+			state = player.advance(frame)
+		}
+		if !reflect.DeepEqual(state, expected) {
+			t.Errorf("Playback with %s frames differs: expected %v, got %v", frame, expected, state)
+		}
+	}
+}
+
+func TestReplaySaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clobber-replays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	replay, _ := playRecordedGame()
+	for i, score := range []int{3, 10, 7} {
+		replay.Score = score
+		replay.Recorded = time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC)
+		if err := replay.Save(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Games recorded at the same time don't overwrite each other
+	replay.Score = 1
+	if err := replay.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if replays, err := ListReplays(dir); err != nil || len(replays) != 4 {
+		t.Fatalf("Expected 4 replays, got %v (%v)", replays, err)
+	}
+	loaded, err := LoadReplay(replay.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Inputs, replay.Inputs) || loaded.Seed != replay.Seed || loaded.Duration != replay.Duration {
+		t.Errorf("Loaded replay differs from the saved one: %v", loaded)
+	}
+
+	// Only the best replays are kept, along with the ones in the highscore table
+	highscores := []Highscore{{Score: 1, Mode: WrapMode, Replay: filepath.Base(replay.Path)}, {Score: 2, Mode: WrapMode}}
+	if err := PruneReplays(dir, 2, highscores); err != nil {
+		t.Fatal(err)
+	}
+	replays, err := ListReplays(dir)
+	if err != nil || len(replays) != 3 || replays[0].Score != 10 || replays[1].Score != 7 || replays[2].Score != 1 {
+		t.Errorf("Expected the best 2 replays and the highscore's to be kept, got %v (%v)", replays, err)
+	}
+}
+
+func TestMultiplayerReplayPlayback(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewMultiplayerEngine(Size{20, 15}, &Mode{Name: WrapMode}, 2, 3, clock.Now)
+	state := engine.State()
+	turns := []Direction{{1, 0}, {0, -1}, {-1, 0}, {0, 1}}
+	for i := 0; i < 500 && !state.Dead; i++ {
+		clock.Advance(37 * time.Millisecond)
+		engine.Step(Input{Direction: turns[i/7%4], Player: 0})
+		state = engine.Step(Input{Direction: turns[(i/5+1)%4], Player: 1})
+	}
+
+	// The inputs of both players survive saving and loading
+	data, err := json.Marshal(engine.Replay())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := &Replay{}
+	if err := json.Unmarshal(data, replay); err != nil {
+		t.Fatal(err)
+	}
+	secondPlayer := 0
+	for _, input := range replay.Inputs {
+		secondPlayer += input.Player
+	}
+	if replay.Players != 2 || secondPlayer == 0 {
+		t.Fatalf("Expected the inputs of both players, got %v", replay.Inputs)
+	}
+	player := newReplayer(replay)
+	var played State
+	for !player.finished() {
+		played = player.advance(16 * time.Millisecond)
+	}
+	if !reflect.DeepEqual(played, state) {
+		t.Errorf("Playback differs: expected %v, got %v", state, played)
+	}
+}
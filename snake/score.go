@@ -1,112 +1,176 @@
 package snake
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Dids/clobber/util"
 )
 
-// Score information for the game
-type Score struct {
-	score     int
-	highscore int
-}
+// maxHighscores is the number of entries kept in the highscore table of each mode
+const maxHighscores = 10
 
-// NewScore creates and returns a new Score object
-func NewScore() *Score {
-	score := &Score{}
-	score.score = 0
-	score.highscore = score.loadHighscore()
-	return score
-}
+// highscoresLockTimeout is how long to wait for another game to finish updating the highscores
+const highscoresLockTimeout = 5 * time.Second
+
+// Highscore is a single entry in the highscore table
+type Highscore struct {
+	// Name of the player
+	Name string `json:"name"`
+
+	Score int `json:"score"`
 
-// GetScore returns the current score
-func (score *Score) GetScore() int {
-	return score.score
+	// When the game ended, and how long it took (in game time)
+	Date     time.Time     `json:"date"`
+	Duration time.Duration `json:"duration"`
+
+	// Level mode the game was played in
+	Mode string `json:"mode"`
+
+	// Clover revision being built while playing (if any)
+	Revision string `json:"revision,omitempty"`
+
+	// Name of the replay of the game in the replays directory (if any)
+	Replay string `json:"replay,omitempty"`
 }
 
-// GetHighscore returns the best score
-func (score *Score) GetHighscore() int {
-	return score.highscore
+// highscoreTable is the format of the highscore file
+type highscoreTable struct {
+	Entries []Highscore `json:"entries"`
 }
 
-// SetScore updates the current and best score
-func (score *Score) SetScore(newScore int) {
-	score.score = newScore
-	if score.score > score.highscore {
-		score.highscore = score.score
-		score.saveHighscore(score.highscore)
+// LoadHighscores loads the highscore table, best first (empty if there's none yet)
+func LoadHighscores(path string) ([]Highscore, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	table := &highscoreTable{}
+	if err := json.Unmarshal(data, table); err != nil {
+		return nil, fmt.Errorf("invalid highscore file %s: %s", path, err)
 	}
+	return table.Entries, nil
 }
 
-// IncrementScore updates the current and best score
-func (score *Score) IncrementScore() {
-	newScore := score.score + 1
-	score.SetScore(newScore)
+// RecoverHighscores moves a highscore table that can't be parsed aside (adding a ".broken" suffix),
+// so it doesn't keep new scores from being added, returning where it was moved to ("" if it's fine)
+func RecoverHighscores(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		// Missing tables are fine, and unreadable ones can't be fixed here
+		return "", nil
+	}
+	if json.Unmarshal(data, &highscoreTable{}) == nil {
+		return "", nil
+	}
+	brokenPath := path + "." + time.Now().Format("20060102-150405") + ".broken"
+	if err := os.Rename(path, brokenPath); err != nil {
+		return "", err
+	}
+	return brokenPath, nil
 }
 
-func (score *Score) createHighscoreFile() {
-	if _, err := os.Stat(util.GetScorePath()); err != nil {
-		// Create the file
-		newScoreFile, err := os.Create(util.GetScorePath())
-		if err != nil {
-			log.Fatal("Failed to create highscore file:", err)
-		}
-
-		// Make sure to close the file when done
-		defer newScoreFile.Close()
-
-		// Write a zero score to the file
-		if _, err := fmt.Fprintf(newScoreFile, "%d", 0); err != nil {
-			log.Fatal("Failed to write to highscore file:", err)
+// HighscoresForMode returns the entries of the supplied mode (see Mode.ID)
+func HighscoresForMode(entries []Highscore, mode string) []Highscore {
+	var modeEntries []Highscore
+	for _, entry := range entries {
+		if entry.Mode == mode {
+			modeEntries = append(modeEntries, entry)
 		}
 	}
+	return modeEntries
 }
 
-func (score *Score) loadHighscore() int {
-	// Make sure that the score file exists
-	score.createHighscoreFile()
+// AddHighscore adds an entry to the highscore table of its mode, returning the updated table of the
+// mode along with the rank of the new entry (starting at 1, or 0 if it didn't make it to the table)
+func AddHighscore(path string, entry Highscore) ([]Highscore, int, error) {
+	lock, err := lockHighscores(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer lock.Release()
 
-	// Get the current highscore from the file
-	bytes, err := ioutil.ReadFile(util.GetScorePath())
+	entries, err := LoadHighscores(path)
 	if err != nil {
-		log.Fatal("Failed to read highscore file:", err)
+		return nil, 0, err
 	}
 
-	// Parse and load the highscore
-	highscoreString := string(bytes)
-	highscoreString = strings.TrimSpace(highscoreString)
-	if len(highscoreString) > 0 {
-		highscore, err := strconv.Atoi(highscoreString)
-		if err != nil {
-			log.Fatal("Failed to parse highscore:", err)
+	// Ties go to whoever got the score first
+	modeEntries := HighscoresForMode(entries, entry.Mode)
+	rank := sort.Search(len(modeEntries), func(i int) bool { return modeEntries[i].Score < entry.Score })
+	if rank >= maxHighscores {
+		return modeEntries, 0, nil
+	}
+	index := sort.Search(len(entries), func(i int) bool { return entries[i].Score < entry.Score })
+	entries = append(entries[:index], append([]Highscore{entry}, entries[index:]...)...)
+
+	// Only keep the best entries of each mode
+	kept := entries[:0]
+	counts := make(map[string]int)
+	for _, existing := range entries {
+		if counts[existing.Mode] < maxHighscores {
+			kept = append(kept, existing)
 		}
-		return highscore
+		counts[existing.Mode]++
 	}
-
-	return 0
+	if err := saveHighscores(path, kept); err != nil {
+		return nil, 0, err
+	}
+	return HighscoresForMode(kept, entry.Mode), rank + 1, nil
 }
 
-func (score *Score) saveHighscore(highscore int) {
-	// Make sure that the score file exists
-	score.createHighscoreFile()
+// MigrateScoreFile converts the old file with a single score into a highscore table,
+// unless there's already a table, removing the old file once it's converted
+func MigrateScoreFile(legacyPath string, path string, name string) error {
+	data, err := ioutil.ReadFile(legacyPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return os.Remove(legacyPath)
+	}
 
-	// Open the file
-	newScoreFile, err := os.OpenFile(util.GetScorePath(), os.O_WRONLY, os.ModeAppend)
-	if err != nil {
-		log.Fatal("Failed to open highscore file:", err)
+	// A broken score file isn't worth keeping
+	if score, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && score > 0 {
+		entry := Highscore{Name: name, Score: score, Mode: ClassicMode}
+		if fileInfo, err := os.Stat(legacyPath); err == nil {
+			entry.Date = fileInfo.ModTime()
+		}
+		if _, _, err := AddHighscore(path, entry); err != nil {
+			return err
+		}
 	}
+	return os.Remove(legacyPath)
+}
 
-	// Make sure to close the file when done
-	defer newScoreFile.Close()
+// saveHighscores atomically replaces the highscore table
+func saveHighscores(path string, entries []Highscore) error {
+	data, err := json.MarshalIndent(&highscoreTable{Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(path, append(data, '\n'), 0644)
+}
 
-	// Write the score to the file
-	if _, err := fmt.Fprintf(newScoreFile, "%d\n", highscore); err != nil {
-		log.Fatal("Failed to write to highscore file:", err)
+// lockHighscores locks the highscore table, so games finishing at the same
+// time (eg. in different terminals) don't overwrite each other's scores
+func lockHighscores(path string) (*util.Lock, error) {
+	deadline := time.Now().Add(highscoresLockTimeout)
+	for {
+		lock, err := util.AcquireLock(path + ".lock")
+		if _, locked := err.(*util.LockedError); !locked || time.Now().After(deadline) {
+			return lock, err
+		}
+		time.Sleep(50 * time.Millisecond)
 	}
 }
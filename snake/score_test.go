@@ -0,0 +1,137 @@
+package snake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dids/clobber/util"
+)
+
+func TestAddHighscore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clobber-highscores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "highscores.json")
+
+	var tests = []struct {
+		name  string
+		score int
+		rank  int
+	}{
+		{"first", 5, 1},
+		{"second", 10, 1},
+		{"tie", 5, 3},
+		{"third", 7, 2},
+	}
+	for _, test := range tests {
+		_, rank, err := AddHighscore(path, Highscore{Name: test.name, Score: test.score, Mode: ClassicMode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rank != test.rank {
+			t.Errorf("%s: expected rank %d, got %d", test.name, test.rank, rank)
+		}
+	}
+
+	// Only the best scores are kept, with ties going to the earlier score
+	for i := 0; i < maxHighscores; i++ {
+		AddHighscore(path, Highscore{Name: "filler", Score: 6, Mode: ClassicMode})
+	}
+	if _, rank, _ := AddHighscore(path, Highscore{Name: "too low", Score: 6, Mode: ClassicMode}); rank != 0 {
+		t.Errorf("Expected a score tied with the last entry not to make it, got rank %d", rank)
+	}
+
+	// Each mode has its own table
+	if _, rank, _ := AddHighscore(path, Highscore{Name: "wrap", Score: 1, Mode: WrapMode}); rank != 1 {
+		t.Errorf("Expected the first score of a mode to rank first, got rank %d", rank)
+	}
+	all, err := LoadHighscores(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != maxHighscores+1 || len(HighscoresForMode(all, WrapMode)) != 1 {
+		t.Errorf("Expected %d classic entries and 1 wrap entry, got %v", maxHighscores, all)
+	}
+	entries := HighscoresForMode(all, ClassicMode)
+	if len(entries) != maxHighscores || entries[0].Name != "second" || entries[1].Name != "third" || entries[2].Name != "filler" {
+		t.Errorf("Unexpected highscores: %v", entries)
+	}
+}
+
+func TestMigrateScoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clobber-highscores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	legacyPath := filepath.Join(dir, ".score")
+	path := filepath.Join(dir, "highscores.json")
+
+	if err := ioutil.WriteFile(legacyPath, []byte("42"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateScoreFile(legacyPath, path, "hiss"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := LoadHighscores(path)
+	if err != nil || len(entries) != 1 || entries[0].Score != 42 || entries[0].Name != "hiss" || entries[0].Date.IsZero() {
+		t.Errorf("Expected the old score to be migrated, got %v (%v)", entries, err)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Error("Expected the old score file to be removed")
+	}
+
+	// A broken score file is dropped
+	if err := ioutil.WriteFile(legacyPath, []byte("not a score"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+	if err := MigrateScoreFile(legacyPath, path, "hiss"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := LoadHighscores(path); len(entries) != 0 {
+		t.Errorf("Expected no highscores, got %v", entries)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Error("Expected the broken score file to be removed")
+	}
+}
+
+func TestCorruptHighscores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clobber-highscores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("CLOBBER_HOME", os.Getenv("CLOBBER_HOME"))
+	os.Setenv("CLOBBER_HOME", dir)
+	path := util.GetHighscoresPath()
+	if err := ioutil.WriteFile(path, []byte(`{"entries": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The game starts with an empty table instead of exiting
+	game := NewGame(DefaultConfig())
+	if len(game.highscores) != 0 {
+		t.Errorf("Expected no highscores, got %v", game.highscores)
+	}
+	broken, _ := filepath.Glob(path + ".*.broken")
+	if len(broken) != 1 {
+		t.Fatalf("Expected the broken table to be moved aside, got %v", broken)
+	}
+	if data, _ := ioutil.ReadFile(broken[0]); string(data) != `{"entries": [` {
+		t.Errorf("Expected the broken table to be kept, got %s", data)
+	}
+
+	// New scores can be added again
+	if _, rank, err := AddHighscore(path, Highscore{Name: "hiss", Score: 1, Mode: ClassicMode}); err != nil || rank != 1 {
+		t.Errorf("Expected a new table, got rank %d (%v)", rank, err)
+	}
+	if brokenPath, err := RecoverHighscores(path); err != nil || len(brokenPath) > 0 {
+		t.Errorf("Expected a valid table to be left alone, got %s (%v)", brokenPath, err)
+	}
+}
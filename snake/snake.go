@@ -11,8 +11,14 @@ type Snake struct {
 	// Direction of the snake
 	direction Direction
 
+	// Direction of the last move, which may differ from the direction if it was just changed
+	moved Direction
+
 	// Dead or alive
 	dead bool
+
+	// Size of the level the snake wraps around the edges of (zero if it doesn't)
+	wrap Size
 }
 
 // NewSnake spawns a hissing creature
@@ -26,6 +32,7 @@ func NewSnake(position Position, direction Direction) *Snake {
 		position,
 	}
 	snake.direction = direction
+	snake.moved = direction
 	snake.dead = false
 
 	return snake
@@ -57,8 +64,9 @@ func (snake *Snake) GetHead() Position {
 // UpdateDirection will set the snake direction,
 // so long as it's not the opposite direction
 func (snake *Snake) UpdateDirection(direction Direction) {
-	// Don't allow going in opposite directions, as that would just kill the snake
-	if snake.direction.X-direction.X == 0 || snake.direction.Y-direction.Y == 0 {
+	// Don't allow going in the opposite direction of the last move, as that would just kill the snake
+	// (even when turning twice before the next move)
+	if direction.X == -snake.moved.X && direction.Y == -snake.moved.Y {
 		return
 	}
 	snake.direction = direction
@@ -82,6 +90,14 @@ func (snake *Snake) Move() {
 		newPosition.Y++
 	}
 
+	snake.moved = snake.direction
+
+	// Come back on the other side when wrapping around
+	if snake.wrap.Width > 0 {
+		newPosition.X = (newPosition.X + snake.wrap.Width) % snake.wrap.Width
+		newPosition.Y = (newPosition.Y + snake.wrap.Height) % snake.wrap.Height
+	}
+
 	// Check if this new position would result in death
 	if snake.CheckHitbox(newPosition) {
 		snake.dead = true
@@ -0,0 +1,70 @@
+package snake
+
+import "testing"
+
+func TestUpdateDirection(t *testing.T) {
+	up, down, left, right := Direction{0, -1}, Direction{0, 1}, Direction{-1, 0}, Direction{1, 0}
+	tests := []struct {
+		moving    Direction
+		turns     []Direction
+		direction Direction
+	}{
+		{down, []Direction{left}, left},
+		{down, []Direction{right}, right},
+		{down, []Direction{down}, down},
+		{down, []Direction{up}, down},
+		{left, []Direction{right}, left},
+		{right, []Direction{up}, up},
+		// Turning twice before moving must not reverse the snake onto itself
+		{down, []Direction{left, up}, left},
+		{right, []Direction{up, left}, up},
+		{right, []Direction{up, right}, right},
+	}
+	for _, test := range tests {
+		snake := NewSnake(Position{5, 5}, test.moving)
+		for _, turn := range test.turns {
+			snake.UpdateDirection(turn)
+		}
+		if snake.direction != test.direction {
+			t.Errorf("Turning %v while moving %v: expected %v, got %v", test.turns, test.moving, test.direction, snake.direction)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		direction Direction
+		head      Position
+	}{
+		{Direction{0, 1}, Position{5, 6}},
+		{Direction{-1, 0}, Position{4, 5}},
+		{Direction{1, 0}, Position{6, 5}},
+	}
+	for _, test := range tests {
+		snake := NewSnake(Position{5, 5}, Direction{0, 1})
+		snake.UpdateDirection(test.direction)
+		snake.Move()
+		if snake.GetHead() != test.head || len(snake.body) != 2 || snake.dead {
+			t.Errorf("Moving %v: expected head at %v, got %v (body %v, dead %t)", test.direction, test.head, snake.GetHead(), snake.body, snake.dead)
+		}
+	}
+
+	// Growing keeps the tail in place for a move
+	snake := NewSnake(Position{5, 5}, Direction{0, 1})
+	snake.IncrementSize()
+	snake.Move()
+	if len(snake.body) != 3 || snake.body[0] != (Position{5, 4}) {
+		t.Errorf("Expected the snake to grow, got %v", snake.body)
+	}
+
+	// Running into its own body kills the snake
+	snake = NewSnake(Position{5, 5}, Direction{1, 0})
+	snake.body = []Position{{4, 4}, {5, 4}, {6, 4}, {6, 5}, {5, 5}}
+	snake.length = len(snake.body)
+	snake.moved = Direction{-1, 0}
+	snake.UpdateDirection(Direction{0, -1})
+	snake.Move()
+	if !snake.dead {
+		t.Errorf("Expected the snake to die, got %v", snake.body)
+	}
+}
@@ -0,0 +1,119 @@
+package snake
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// BuildStatus is the progress of the Clover build running alongside the game
+type BuildStatus struct {
+	// Title of the current build step (empty if there's none)
+	Step string
+
+	// Start time of the current build step
+	Started time.Time
+
+	// Expected duration of the current build step (zero if unknown)
+	Expected time.Duration
+
+	// Expected remaining time of the build when the step started (negative if unknown)
+	Remaining time.Duration
+
+	// Clover revision being built (empty if unknown)
+	Revision string
+}
+
+// buildResult is the outcome of the Clover build, shown once it has finished
+type buildResult struct {
+	message   string
+	succeeded bool
+}
+
+// SetStatus updates the build progress shown at the bottom of the game
+func (game *Game) SetStatus(status BuildStatus) {
+	game.statusMutex.Lock()
+	defer game.statusMutex.Unlock()
+	game.status = &status
+}
+
+// Finish shows the result of the build, after which the
+// game can either be exited or played for as long as you like
+func (game *Game) Finish(message string, succeeded bool) {
+	game.statusMutex.Lock()
+	defer game.statusMutex.Unlock()
+	game.result = &buildResult{message, succeeded}
+}
+
+// revision returns the Clover revision being built (empty if not building)
+func (game *Game) revision() string {
+	game.statusMutex.Lock()
+	defer game.statusMutex.Unlock()
+	if game.status == nil {
+		return ""
+	}
+	return game.status.Revision
+}
+
+// statusRow returns the text and background color of the status row,
+// which shows either the build progress or its result
+func (game *Game) statusRow() (string, termbox.Attribute) {
+	game.statusMutex.Lock()
+	defer game.statusMutex.Unlock()
+
+	var text string
+	color := termbox.ColorBlue
+	keys := game.bindings.keys
+	quit := describeKeys(keys.Quit)
+	switch {
+	case game.result != nil && game.result.succeeded:
+		text = "✔ " + game.result.message + " (press " + quit + " to exit, or keep playing)"
+		color = termbox.ColorGreen
+	case game.result != nil:
+		text = "✘ " + game.result.message + " (press " + quit + " to exit)"
+		color = termbox.ColorRed
+	case game.replayer != nil:
+		replay := game.replayer.replay
+		text = fmt.Sprintf("Replay of %s, %s/%s", replay.Recorded.Format("2006-01-02 15:04"), formatStatusDuration(game.replayer.elapsed), formatStatusDuration(replay.Duration))
+		if game.speed != 1 {
+			text += fmt.Sprintf(" at %gx", game.speed)
+		}
+		if game.replayer.finished() {
+			text += ", finished"
+		}
+		text += " (press " + quit + " to exit)"
+	case game.status == nil && game.players > 1:
+		text = fmt.Sprintf("Player 1 moves with %s, player 2 with %s, %s for help, %s to exit", describeDirections(keys.Player1), describeDirections(keys.Player2), describeKeys(keys.Help), quit)
+	case game.status == nil && game.autopiloting:
+		text = fmt.Sprintf("The autopilot is playing, use %s to take over, %s to exit", describeDirections(keys.Player1), quit)
+	case game.status == nil:
+		text = fmt.Sprintf("Use %s to move, %s for help, %s to exit", describeDirections(keys.Player1), describeKeys(keys.Help), quit)
+	case len(game.status.Step) > 0:
+		elapsed := time.Since(game.status.Started)
+		text = "◌ " + game.status.Step + " " + formatStatusDuration(elapsed)
+		if game.status.Expected > 0 {
+			text += "/~" + formatStatusDuration(game.status.Expected)
+		}
+		if game.status.Remaining >= 0 {
+			// Count down from the estimate made when the step started
+			remaining := game.status.Remaining - elapsed
+			if remaining < time.Second {
+				text += ", almost done"
+			} else {
+				text += ", about " + formatStatusDuration(remaining) + " left"
+			}
+		}
+	default:
+		text = "◌ Preparing the Clover build.."
+	}
+	return " " + text, color
+}
+
+// formatStatusDuration rounds a duration to seconds (or minutes, if it's long)
+func formatStatusDuration(duration time.Duration) string {
+	if duration >= 10*time.Minute {
+		return duration.Round(time.Minute).String()
+	}
+	return duration.Round(time.Second).String()
+}
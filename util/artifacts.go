@@ -0,0 +1,299 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChecksumsName is the file name of the artifact checksums
+const ChecksumsName = "SHA256SUMS"
+
+// Artifact is a single build's directory in the artifact store
+type Artifact struct {
+	ID      string
+	Path    string
+	Info    *BuildInfo
+	Size    int64
+	Created time.Time
+}
+
+// Complete returns true if the artifact has both a build manifest and checksums,
+// which are only written after a build has finished successfully
+func (artifact *Artifact) Complete() bool {
+	if artifact.Info == nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(artifact.Path, ChecksumsName))
+	return err == nil
+}
+
+// Status returns a short description of the artifact's state
+func (artifact *Artifact) Status() string {
+	if artifact.Complete() {
+		return "complete"
+	}
+	return "incomplete"
+}
+
+// GetArtifactsPath returns the full path to the artifacts directory
+func GetArtifactsPath() string {
+	return GetClobberPath() + "/artifacts"
+}
+
+// GetArtifactName returns a unique directory name for the artifacts of a build,
+// formatted as "<describe>-<toolchain>-<timestamp>"
+func GetArtifactName(info *BuildInfo) string {
+	describe := info.Clover.Describe
+	if len(describe) == 0 {
+		describe = "unknown"
+	}
+	name := describe + "-" + info.Toolchain + "-" + info.Started.Format("20060102-150405")
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// WriteChecksums writes the SHA-256 checksums of all files
+// in a directory (non-recursive) to its SHA256SUMS file
+func WriteChecksums(dir string) error {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() || fileInfo.Name() == ChecksumsName {
+			continue
+		}
+		checksum, err := SHA256File(filepath.Join(dir, fileInfo.Name()))
+		if err != nil {
+			return err
+		}
+		// Use the same format as shasum/sha256sum, so the file can be verified with them
+		lines = append(lines, fmt.Sprintf("%s  %s", checksum, fileInfo.Name()))
+	}
+	sort.Strings(lines)
+
+	return ioutil.WriteFile(filepath.Join(dir, ChecksumsName), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// ReadChecksums returns the checksums from a directory's SHA256SUMS file,
+// mapped from file name to checksum
+func ReadChecksums(dir string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ChecksumsName))
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) == 2 {
+			checksums[fields[1]] = fields[0]
+		}
+	}
+	return checksums, nil
+}
+
+// CopyArtifact copies a file into an artifact directory, keeping its name
+func CopyArtifact(source string, dir string) (string, error) {
+	destination := filepath.Join(dir, filepath.Base(source))
+	if err := CopyFile(source, destination); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// FindFiles returns the files matching any of the glob patterns that were modified since the
+// supplied time (at the resolution of the file system), skipping duplicates
+func FindFiles(since time.Time, patterns ...string) []string {
+	var files []string
+	found := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() || info.ModTime().Before(since.Truncate(time.Second)) {
+				continue
+			}
+			if !found[match] {
+				found[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files
+}
+
+// ListArtifacts returns all artifacts in a store directory, newest first
+func ListArtifacts(dir string) ([]*Artifact, error) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var artifacts []*Artifact
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() {
+			continue
+		}
+		artifact, err := readArtifact(dir, fileInfo)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].Created.After(artifacts[j].Created)
+	})
+	return artifacts, nil
+}
+
+// GetArtifact returns the artifact with the given ID (or a unique prefix of it)
+func GetArtifact(dir string, id string) (*Artifact, error) {
+	artifacts, err := ListArtifacts(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*Artifact
+	for _, artifact := range artifacts {
+		if artifact.ID == id {
+			return artifact, nil
+		}
+		if strings.HasPrefix(artifact.ID, id) {
+			matches = append(matches, artifact)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("artifact '%s' not found", id)
+	} else if len(matches) > 1 {
+		return nil, fmt.Errorf("artifact '%s' is ambiguous (%d matches)", id, len(matches))
+	}
+	return matches[0], nil
+}
+
+// readArtifact reads the manifest and size of a single artifact directory
+func readArtifact(dir string, fileInfo os.FileInfo) (*Artifact, error) {
+	artifact := &Artifact{
+		ID:      fileInfo.Name(),
+		Path:    filepath.Join(dir, fileInfo.Name()),
+		Created: fileInfo.ModTime(),
+	}
+	if info, err := ReadBuildInfo(filepath.Join(artifact.Path, BuildManifestName)); err == nil {
+		artifact.Info = info
+		if !info.Started.IsZero() {
+			artifact.Created = info.Started
+		}
+	}
+	err := filepath.Walk(artifact.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			artifact.Size += info.Size()
+		}
+		return nil
+	})
+	return artifact, err
+}
+
+// VerifyArtifact validates the files of an artifact against its SHA256SUMS,
+// returning a list of problems (empty if everything matches)
+func VerifyArtifact(dir string) ([]string, error) {
+	checksums, err := ReadChecksums(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checksum, err := SHA256File(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			problems = append(problems, name+": missing")
+		} else if err != nil {
+			return nil, err
+		} else if checksum != checksums[name] {
+			problems = append(problems, name+": checksum mismatch")
+		}
+	}
+
+	// Files that aren't in SHA256SUMS were added after the build
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileInfo := range fileInfos {
+		if _, ok := checksums[fileInfo.Name()]; !ok && !fileInfo.IsDir() && fileInfo.Name() != ChecksumsName {
+			problems = append(problems, fileInfo.Name()+": not in "+ChecksumsName)
+		}
+	}
+	return problems, nil
+}
+
+// SelectArtifactsToPrune returns the artifacts (sorted newest first) that should be removed,
+// keeping at least the newest "keep" artifacts (ignored if negative) and anything
+// newer than "olderThan" (ignored if zero)
+func SelectArtifactsToPrune(artifacts []*Artifact, keep int, olderThan time.Duration, now time.Time) []*Artifact {
+	var pruned []*Artifact
+	for index, artifact := range artifacts {
+		if keep >= 0 && index < keep {
+			continue
+		}
+		if olderThan > 0 && now.Sub(artifact.Created) < olderThan {
+			continue
+		}
+		pruned = append(pruned, artifact)
+	}
+	return pruned
+}
+
+// ParseAge parses a duration like time.ParseDuration, but also supports
+// days and weeks (eg. "30d" or "2w")
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(value, suffix) {
+			count, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+			if err != nil || count < 0 {
+				return 0, errors.New("invalid age: " + value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, errors.New("invalid age: " + value)
+	}
+	return duration, nil
+}
+
+// FormatSize returns a human readable file size (eg. "12.3 MB")
+func FormatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
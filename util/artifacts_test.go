@@ -0,0 +1,199 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetArtifactName(t *testing.T) {
+	info := &BuildInfo{
+		Toolchain: "XCODE8",
+		Clover:    CloverInfo{Describe: "v2.5k_r5120-3-gabcdef/x"},
+		Started:   time.Date(2020, 11, 3, 14, 5, 9, 0, time.Local),
+	}
+	if name := GetArtifactName(info); name != "v2.5k_r5120-3-gabcdef_x-XCODE8-20201103-140509" {
+		t.Errorf("Invalid artifact name: %s", name)
+	}
+}
+
+func TestWriteChecksums(t *testing.T) {
+	artifactPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(artifactPath)
+
+	if err := ioutil.WriteFile(artifactPath+"/Clover.pkg", []byte("clobber"), 0644); err != nil {
+		t.Fatalf("Failed to write artifact: %s", err)
+	}
+	if err := os.Mkdir(artifactPath+"/ignored", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %s", err)
+	}
+
+	if err := WriteChecksums(artifactPath); err != nil {
+		t.Fatalf("Failed to write checksums: %s", err)
+	}
+	checksums, err := ReadChecksums(artifactPath)
+	if err != nil {
+		t.Fatalf("Failed to read checksums: %s", err)
+	}
+	if len(checksums) != 1 || checksums["Clover.pkg"] != "45ae8866a51cd279e0cfad3f947c2d4d76c8f6ef6db8726081ce4535bf78422c" {
+		t.Errorf("Invalid checksums: %v", checksums)
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	symPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(symPath)
+
+	os.MkdirAll(symPath+"/CloverISO-5120", 0755)
+	ioutil.WriteFile(symPath+"/Clover_r5120.pkg", nil, 0644)
+	ioutil.WriteFile(symPath+"/CloverISO-5120/Clover-v2.5k-5120-X64.iso", nil, 0644)
+
+	// A package left over from an earlier build
+	ioutil.WriteFile(symPath+"/Clover_r5119.pkg", nil, 0644)
+	started := time.Now().Add(-time.Minute)
+	os.Chtimes(symPath+"/Clover_r5119.pkg", started.Add(-time.Hour), started.Add(-time.Hour))
+
+	files := FindFiles(started, symPath+"/*.pkg", symPath+"/*.iso", symPath+"/*/*.iso", symPath+"/*.pkg")
+	if len(files) != 2 || files[0] != symPath+"/Clover_r5120.pkg" || files[1] != symPath+"/CloverISO-5120/Clover-v2.5k-5120-X64.iso" {
+		t.Errorf("Invalid files: %v", files)
+	}
+	if files := FindFiles(time.Time{}, symPath+"/*.pkg"); len(files) != 2 {
+		t.Errorf("Expected all packages without a start time, got %v", files)
+	}
+}
+
+func TestVerifyArtifact(t *testing.T) {
+	artifactPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(artifactPath)
+
+	ioutil.WriteFile(artifactPath+"/Clover.pkg", []byte("clobber"), 0644)
+	ioutil.WriteFile(artifactPath+"/Clover.iso", []byte("clobber"), 0644)
+	if err := WriteChecksums(artifactPath); err != nil {
+		t.Fatalf("Failed to write checksums: %s", err)
+	}
+	if problems, err := VerifyArtifact(artifactPath); err != nil || len(problems) != 0 {
+		t.Fatalf("Expected a valid artifact, got %v (%v)", problems, err)
+	}
+
+	ioutil.WriteFile(artifactPath+"/Clover.pkg", []byte("modified"), 0644)
+	os.Remove(artifactPath + "/Clover.iso")
+	ioutil.WriteFile(artifactPath+"/extra.txt", nil, 0644)
+	problems, err := VerifyArtifact(artifactPath)
+	if err != nil {
+		t.Fatalf("Failed to verify artifact: %s", err)
+	}
+	expected := []string{"Clover.iso: missing", "Clover.pkg: checksum mismatch", "extra.txt: not in SHA256SUMS"}
+	if strings.Join(problems, ",") != strings.Join(expected, ",") {
+		t.Errorf("Invalid problems: %v", problems)
+	}
+}
+
+func TestListArtifacts(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(storePath)
+
+	for index, name := range []string{"v1.0-XCODE8-20201101-100000", "v2.0-XCODE8-20201102-100000"} {
+		os.Mkdir(storePath+"/"+name, 0755)
+		ioutil.WriteFile(storePath+"/"+name+"/Clover.pkg", []byte("clobber"), 0644)
+		info := &BuildInfo{Toolchain: "XCODE8", Started: time.Date(2020, 11, 1+index, 10, 0, 0, 0, time.Local)}
+		info.Write(storePath + "/" + name + "/" + BuildManifestName)
+	}
+	WriteChecksums(storePath + "/v2.0-XCODE8-20201102-100000")
+
+	artifacts, err := ListArtifacts(storePath)
+	if err != nil {
+		t.Fatalf("Failed to list artifacts: %s", err)
+	}
+	if len(artifacts) != 2 || artifacts[0].ID != "v2.0-XCODE8-20201102-100000" || artifacts[1].ID != "v1.0-XCODE8-20201101-100000" {
+		t.Fatalf("Invalid artifacts: %v", artifacts)
+	}
+	if artifacts[0].Status() != "complete" || artifacts[1].Status() != "incomplete" {
+		t.Errorf("Invalid statuses: %s, %s", artifacts[0].Status(), artifacts[1].Status())
+	}
+
+	if artifact, err := GetArtifact(storePath, "v1"); err != nil || artifact.ID != "v1.0-XCODE8-20201101-100000" {
+		t.Errorf("Failed to get artifact by prefix: %v (%v)", artifact, err)
+	}
+	if _, err := GetArtifact(storePath, "v"); err == nil {
+		t.Error("Expected an ambiguous prefix to fail")
+	}
+	if _, err := GetArtifact(storePath, "v3"); err == nil {
+		t.Error("Expected a missing artifact to fail")
+	}
+}
+
+func TestSelectArtifactsToPrune(t *testing.T) {
+	now := time.Date(2020, 12, 31, 0, 0, 0, 0, time.Local)
+	var artifacts []*Artifact
+	for days := 0; days < 5; days++ {
+		artifacts = append(artifacts, &Artifact{ID: strconv.Itoa(days), Created: now.Add(-time.Duration(days) * 10 * 24 * time.Hour)})
+	}
+
+	tests := []struct {
+		keep      int
+		olderThan time.Duration
+		expected  string
+	}{
+		{-1, 0, "0,1,2,3,4"},
+		{2, 0, "2,3,4"},
+		{-1, 25 * 24 * time.Hour, "3,4"},
+		{4, 25 * 24 * time.Hour, "4"},
+		{10, 0, ""},
+	}
+	for _, test := range tests {
+		var ids []string
+		for _, artifact := range SelectArtifactsToPrune(artifacts, test.keep, test.olderThan, now) {
+			ids = append(ids, artifact.ID)
+		}
+		if strings.Join(ids, ",") != test.expected {
+			t.Errorf("Invalid artifacts to prune for keep %d and age %s: %v", test.keep, test.olderThan, ids)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+	}
+	for value, expected := range tests {
+		if age, err := ParseAge(value); err != nil || age != expected {
+			t.Errorf("Invalid age for %s: %s (%v)", value, age, err)
+		}
+	}
+	for _, value := range []string{"", "d", "-1d", "soon"} {
+		if _, err := ParseAge(value); err == nil {
+			t.Errorf("Expected %q to be invalid", value)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:        "512 B",
+		12345:      "12.3 kB",
+		5500000:    "5.5 MB",
+		1000000000: "1.0 GB",
+	}
+	for size, expected := range tests {
+		if formatted := FormatSize(size); formatted != expected {
+			t.Errorf("Invalid size for %d: %s", size, formatted)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BuildManifestName is the file name of the build manifest
+const BuildManifestName = "build-manifest.json"
+
+// BuildInfo describes a Clover build and the environment it was built in
+type BuildInfo struct {
+	// Clobber version and workspace
+	ClobberVersion string `json:"clobberVersion"`
+	Workspace      string `json:"workspace"`
+
+	// Host environment
+	Host     string `json:"host"`
+	Xcode    string `json:"xcode,omitempty"`
+	Compiler string `json:"compiler,omitempty"`
+	Clang    string `json:"clang"`
+
+	// Toolchain used for building Clover (eg. XCODE8)
+	Toolchain string `json:"toolchain"`
+
+	// Clover revision and external packages
+	Clover      CloverInfo    `json:"clover"`
+	ExtPackages []PackageInfo `json:"extPackages"`
+
+	// EFI drivers included in the build
+	Drivers []DriverInfo `json:"drivers"`
+
+	// Patches applied to Clover and its installer
+	Patches []string `json:"patches"`
+
+	// Command-line flags used for the build
+	Flags []string `json:"flags"`
+
+	// Build steps and their durations
+	Steps []StepInfo `json:"steps"`
+
+	// Start and end time of the build
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+
+	// Errors encountered while collecting the build information
+	Errors []string `json:"errors,omitempty"`
+}
+
+// CloverInfo describes the Clover source tree
+type CloverInfo struct {
+	Revision     string   `json:"revision,omitempty"`
+	Describe     string   `json:"describe"`
+	SHA          string   `json:"sha"`
+	Path         string   `json:"path"`
+	External     bool     `json:"external,omitempty"`
+	LocalChanges []string `json:"localChanges,omitempty"`
+}
+
+// PackageInfo describes an external package and its commit
+type PackageInfo struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit"`
+}
+
+// DriverInfo describes an EFI driver and its checksum
+type DriverInfo struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// StepInfo describes a build step and how long it took
+// (stored in seconds, like the durations of the JSON output events)
+type StepInfo struct {
+	ID       string        `json:"id"`
+	Title    string        `json:"title"`
+	Duration time.Duration `json:"duration"`
+}
+
+// MarshalJSON stores the duration in seconds
+func (step StepInfo) MarshalJSON() ([]byte, error) {
+	type plainStepInfo StepInfo
+	return json.Marshal(struct {
+		plainStepInfo
+		Duration float64 `json:"duration"`
+	}{plainStepInfo(step), step.Duration.Seconds()})
+}
+
+// UnmarshalJSON loads a step with its duration stored in seconds
+func (step *StepInfo) UnmarshalJSON(data []byte) error {
+	type plainStepInfo StepInfo
+	stored := struct {
+		*plainStepInfo
+		Duration float64 `json:"duration"`
+	}{plainStepInfo: (*plainStepInfo)(step)}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	step.Duration = time.Duration(math.Round(stored.Duration * float64(time.Second)))
+	return nil
+}
+
+// CollectBuildInfo probes the host environment and the Clover source tree,
+// recording any failing probes in Errors instead of failing entirely
+func CollectBuildInfo(toolchain string) *BuildInfo {
+	info := &BuildInfo{
+		Workspace: GetWorkspace(),
+		Toolchain: toolchain,
+		Started:   time.Now(),
+	}
+
+	// Get the host OS version (falling back to uname when not on macOS)
+	if macosVersion, err := probeCommand("", "sw_vers", "-productVersion"); err == nil {
+		info.Host = "macOS " + macosVersion
+	} else if unameVersion, unameErr := probeCommand("", "uname", "-sr"); unameErr == nil {
+		info.Host = unameVersion
+	} else {
+		info.addError("host", err)
+	}
+
+	// Get the Xcode and clang versions
+	if xcodeVersion, err := probeCommand("", "xcodebuild", "-version"); err == nil {
+		info.Xcode = xcodeVersion
+	} else if strings.HasPrefix(toolchain, "XCODE") {
+		info.addError("xcode", err)
+	}
+	if clangVersion, err := probeCommand("", "clang", "-v"); err == nil {
+		info.Clang = clangVersion
+	} else {
+		info.addError("clang", err)
+	}
+
+	// Get the cross compiler version when not building with Xcode
+	if toolchain != "XCODE8" {
+		if compilerVersion, err := probeCommand("", GetSourcePath()+"/opt/local/cross/bin/x86_64-clover-linux-gnu-gcc", "--version"); err == nil {
+			info.Compiler = compilerVersion
+		} else {
+			info.addError("compiler", err)
+		}
+	}
+
+	// Get the Clover version
+	info.Clover.Path = GetCloverPath()
+	info.Clover.External = IsExternalCloverPath()
+	if sha, describe, err := DescribeRevision(GetCloverPath(), "HEAD"); err == nil {
+		info.Clover.SHA = sha
+		info.Clover.Describe = describe
+	} else {
+		info.addError("clover", err)
+	}
+
+	// Get the versions/commits of each external package
+	if extPackages, err := ioutil.ReadDir(GetExtPath()); err == nil {
+		for _, extPackage := range extPackages {
+			if !extPackage.IsDir() {
+				continue
+			}
+			commit, commitErr := probeCommand(GetExtPath()+"/"+extPackage.Name(), "git", "rev-parse", "HEAD")
+			if commitErr != nil {
+				info.addError(extPackage.Name(), commitErr)
+			}
+			info.ExtPackages = append(info.ExtPackages, PackageInfo{Name: extPackage.Name(), Commit: commit})
+		}
+	} else if !os.IsNotExist(err) {
+		info.addError("extPackages", err)
+	}
+
+	// Get the checksums of all included EFI drivers
+	driversPath := GetCloverPath() + "/CloverPackage/CloverV2/EFI/CLOVER/drivers"
+	if err := filepath.Walk(driversPath, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.IsDir() || !strings.HasSuffix(strings.ToLower(fileInfo.Name()), ".efi") {
+			return nil
+		}
+		checksum, checksumErr := SHA256File(path)
+		if checksumErr != nil {
+			return checksumErr
+		}
+		relativePath, _ := filepath.Rel(driversPath, path)
+		info.Drivers = append(info.Drivers, DriverInfo{Path: relativePath, SHA256: checksum})
+		return nil
+	}); err != nil && !os.IsNotExist(err) {
+		info.addError("drivers", err)
+	}
+	sort.Slice(info.Drivers, func(i, j int) bool { return info.Drivers[i].Path < info.Drivers[j].Path })
+
+	return info
+}
+
+// Summary returns the most important build details as human readable lines
+func (info *BuildInfo) Summary() []string {
+	var summary []string
+	for _, line := range []string{info.Host, info.Xcode, info.Compiler, info.Clang} {
+		if len(line) > 0 {
+			summary = append(summary, line)
+		}
+	}
+	summary = append(summary, "Clover ("+info.Clover.Describe+")")
+	for _, extPackage := range info.ExtPackages {
+		summary = append(summary, extPackage.Name+" ("+extPackage.Commit+")")
+	}
+	return summary
+}
+
+// Write saves the build information as JSON to the supplied path
+func (info *BuildInfo) Write(path string) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadBuildInfo loads build information from a JSON file
+func ReadBuildInfo(path string) (*BuildInfo, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info := &BuildInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// addError records a failed probe
+func (info *BuildInfo) addError(probe string, err error) {
+	info.Errors = append(info.Errors, probe+": "+err.Error())
+}
+
+// SHA256File returns the hex encoded SHA-256 checksum of a file
+func SHA256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// probeCommand runs a command and returns the first line of its combined output
+func probeCommand(dir string, name string, args ...string) (string, error) {
+	probe := exec.Command(name, args...)
+	probe.Dir = dir
+	output, err := probe.CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.Split(string(output), "\n")[0]), nil
+}
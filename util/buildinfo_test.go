@@ -0,0 +1,100 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectBuildInfo(t *testing.T) {
+	repoPath := createTestRepository(t)
+	defer os.RemoveAll(repoPath)
+	SetCloverPath(repoPath)
+	defer SetCloverPath("")
+
+	driversPath := repoPath + "/CloverPackage/CloverV2/EFI/CLOVER/drivers/UEFI"
+	if err := os.MkdirAll(driversPath, 0755); err != nil {
+		t.Fatalf("Failed to create drivers directory: %s", err)
+	}
+	if err := ioutil.WriteFile(driversPath+"/HFSPlus.efi", []byte("clobber"), 0644); err != nil {
+		t.Fatalf("Failed to write driver: %s", err)
+	}
+
+	info := CollectBuildInfo("XCODE8")
+	if info.Clover.Describe != "v2.0" || len(info.Clover.SHA) != 40 || !info.Clover.External {
+		t.Errorf("Invalid Clover information: %+v", info.Clover)
+	}
+	if len(info.Drivers) != 1 || info.Drivers[0].Path != "UEFI/HFSPlus.efi" {
+		t.Fatalf("Invalid drivers: %+v", info.Drivers)
+	}
+	if info.Drivers[0].SHA256 != "45ae8866a51cd279e0cfad3f947c2d4d76c8f6ef6db8726081ce4535bf78422c" {
+		t.Errorf("Invalid driver checksum: %s", info.Drivers[0].SHA256)
+	}
+	if len(info.Host) == 0 {
+		t.Errorf("Host information is missing")
+	}
+
+	summary := strings.Join(info.Summary(), "\n")
+	if !strings.Contains(summary, "Clover (v2.0)") {
+		t.Errorf("Summary is missing the Clover version:\n%s", summary)
+	}
+}
+
+func TestBuildInfoWrite(t *testing.T) {
+	manifestFile, err := ioutil.TempFile("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %s", err)
+	}
+	manifestFile.Close()
+	defer os.Remove(manifestFile.Name())
+
+	info := &BuildInfo{
+		Toolchain: "XCODE8",
+		Clover:    CloverInfo{Describe: "5120", SHA: "abc"},
+		Steps:     []StepInfo{{ID: "build-boot6", Title: "Building Clover (boot6)", Duration: 90500 * time.Millisecond}},
+		Started:   time.Now().Round(time.Second),
+	}
+	if err := info.Write(manifestFile.Name()); err != nil {
+		t.Fatalf("Failed to write build info: %s", err)
+	}
+
+	// Durations are stored in seconds, like in the events of the JSON output
+	if data, _ := ioutil.ReadFile(manifestFile.Name()); !strings.Contains(string(data), `"duration": 90.5`) {
+		t.Errorf("Expected the step duration in seconds:\n%s", data)
+	}
+
+	readInfo, err := ReadBuildInfo(manifestFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to read build info: %s", err)
+	}
+	if readInfo.Toolchain != info.Toolchain || readInfo.Clover.Describe != info.Clover.Describe || !readInfo.Started.Equal(info.Started) {
+		t.Errorf("Build info did not survive a round trip: %+v", readInfo)
+	}
+	if len(readInfo.Steps) != 1 || readInfo.Steps[0].Duration != 90500*time.Millisecond {
+		t.Errorf("Invalid steps: %+v", readInfo.Steps)
+	}
+}
+
+func TestSHA256File(t *testing.T) {
+	file, err := ioutil.TempFile("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %s", err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("Clobber Test\n")
+	file.Close()
+
+	checksum, err := SHA256File(file.Name())
+	if err != nil {
+		t.Fatalf("Failed to calculate checksum: %s", err)
+	}
+	if checksum != "8766630ada24458f4ae6a6616c837590030f5af243dd6eecfd0dca20942ccc02" {
+		t.Errorf("Invalid checksum: %s", checksum)
+	}
+
+	if _, err := SHA256File(""); err == nil {
+		t.Errorf("Failed to detect a missing file")
+	}
+}
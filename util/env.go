@@ -0,0 +1,178 @@
+package util
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultPath is used as PATH in clean-env mode if the host doesn't have one
+const defaultPath = "/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin"
+
+// buildEnvAllowlist contains the host variables that are kept in clean-env mode
+var buildEnvAllowlist = []string{
+	"PATH",
+	"USER",
+	"LOGNAME",
+	"SHELL",
+	"TERM",
+	"LANG",
+	"TMPDIR",
+	"DEVELOPER_DIR",
+	"SDKROOT",
+	"GITHUB_API_TOKEN",
+}
+
+// buildEnvAllowlistPrefixes contains the host variable prefixes that are kept in clean-env mode
+var buildEnvAllowlistPrefixes = []string{
+	"LC_",
+}
+
+// secretVariableNames are the parts of variable names whose values are never logged
+var secretVariableNames = []string{"TOKEN", "SECRET", "KEY", "PASSWORD", "PASSWD", "CREDENTIAL"}
+
+// BuildEnv is the environment for build commands, which is built once
+// and passed explicitly to each command (the process environment is never modified)
+type BuildEnv struct {
+	// Variables of the environment
+	vars map[string]string
+
+	// Names of the variables overridden by Clobber, in the order they were set
+	overrides []string
+
+	// Names of the host variables left out in clean-env mode
+	removed []string
+}
+
+// NewBuildEnv creates the build environment for a toolchain, starting from either
+// the full host environment or only the allowlisted host variables (clean)
+func NewBuildEnv(toolchain string, clean bool) *BuildEnv {
+	env := &BuildEnv{vars: make(map[string]string)}
+
+	// Copy the host environment
+	for _, variable := range os.Environ() {
+		split := strings.SplitN(variable, "=", 2)
+		if len(split) != 2 {
+			continue
+		}
+		if !clean || isAllowedBuildVariable(split[0]) {
+			env.vars[split[0]] = split[1]
+		} else {
+			env.removed = append(env.removed, split[0])
+		}
+	}
+	if len(env.Get("PATH")) == 0 {
+		env.vars["PATH"] = defaultPath
+	}
+
+	// Override HOME (use chroot-like logic for the build process)
+	env.Set("HOME", GetClobberPath())
+	env.Set("TOOLCHAIN_DIR", GetSourcePath()+"/opt/local")
+	env.Set("WORKSPACE", GetCloverPath())
+
+	// Building with GCC requires the cross compiler to be in PATH
+	if toolchain != "XCODE8" {
+		env.Set("PATH", env.Get("PATH")+":"+GetSourcePath()+"/opt/local/cross/bin")
+		// FIXME: ccache: error: Could not find compiler "x86_64-clover-linux-gnu-gcc" in PATH
+		env.Set("GCC53_BIN", "gcc")
+	}
+
+	return env
+}
+
+// Get returns the value of a variable (or an empty string if it's not set)
+func (env *BuildEnv) Get(key string) string {
+	return env.vars[key]
+}
+
+// Set sets the value of a variable, marking it as overridden
+func (env *BuildEnv) Set(key string, value string) {
+	env.vars[key] = value
+	for _, override := range env.overrides {
+		if override == key {
+			return
+		}
+	}
+	env.overrides = append(env.overrides, key)
+}
+
+// Overrides returns the names of all variables set by Clobber
+func (env *BuildEnv) Overrides() []string {
+	return append([]string(nil), env.overrides...)
+}
+
+// Removed returns the names of the host variables left out in clean-env mode
+func (env *BuildEnv) Removed() []string {
+	removed := append([]string(nil), env.removed...)
+	sort.Strings(removed)
+	return removed
+}
+
+// Script returns a bash script, which applies the environment to the
+// current shell when sourced (unsetting removed variables and exporting overrides)
+func (env *BuildEnv) Script() string {
+	script := ""
+	for _, key := range env.Removed() {
+		script += "unset " + key + "\n"
+	}
+	for _, key := range env.overrides {
+		script += "export " + key + "=" + ShellQuote(env.vars[key]) + "\n"
+	}
+	return script
+}
+
+// Environ returns the environment as sorted "KEY=value" strings,
+// suitable for exec.Cmd.Env
+func (env *BuildEnv) Environ() []string {
+	var environ []string
+	for key, value := range env.vars {
+		environ = append(environ, key+"="+value)
+	}
+	sort.Strings(environ)
+	return environ
+}
+
+// String returns the environment with one variable per line, for logging
+// (so the values of secrets like GITHUB_API_TOKEN are redacted)
+func (env *BuildEnv) String() string {
+	var lines []string
+	for key, value := range env.vars {
+		if isSecretVariable(key) {
+			value = "[redacted]"
+		}
+		lines = append(lines, key+"="+value)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// isSecretVariable returns true if the name of a variable suggests its value is a secret
+func isSecretVariable(key string) bool {
+	key = strings.ToUpper(key)
+	for _, secret := range secretVariableNames {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedBuildVariable returns true if a host variable is kept in clean-env mode
+func isAllowedBuildVariable(key string) bool {
+	for _, allowed := range buildEnvAllowlist {
+		if key == allowed {
+			return true
+		}
+	}
+	for _, prefix := range buildEnvAllowlistPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShellQuote quotes a string for safe use as a single bash word
+func ShellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNewBuildEnv(t *testing.T) {
+	defer os.Unsetenv("CLOBBER_TEST_STRAY")
+	os.Setenv("CLOBBER_TEST_STRAY", "stray")
+	homePath := os.Getenv("HOME")
+
+	env := NewBuildEnv("XCODE8", false)
+	if env.Get("HOME") != GetClobberPath() {
+		t.Errorf("HOME was not overridden: %s", env.Get("HOME"))
+	}
+	if env.Get("TOOLCHAIN_DIR") != GetSourcePath()+"/opt/local" {
+		t.Errorf("TOOLCHAIN_DIR was not overridden: %s", env.Get("TOOLCHAIN_DIR"))
+	}
+	if env.Get("WORKSPACE") != GetCloverPath() {
+		t.Errorf("WORKSPACE was not overridden: %s", env.Get("WORKSPACE"))
+	}
+	if env.Get("CLOBBER_TEST_STRAY") != "stray" {
+		t.Errorf("Host variables should be kept by default")
+	}
+	if len(env.Get("GCC53_BIN")) > 0 || strings.Contains(env.Get("PATH"), "/cross/bin") {
+		t.Errorf("GCC variables should not be set for XCODE8")
+	}
+	if os.Getenv("HOME") != homePath {
+		t.Errorf("Process environment was modified")
+	}
+}
+
+func TestNewBuildEnvGCC(t *testing.T) {
+	env := NewBuildEnv("GCC53", false)
+	if !strings.HasSuffix(env.Get("PATH"), ":"+GetSourcePath()+"/opt/local/cross/bin") {
+		t.Errorf("Cross compiler was not added to PATH: %s", env.Get("PATH"))
+	}
+	if env.Get("GCC53_BIN") != "gcc" {
+		t.Errorf("GCC53_BIN was not set: %s", env.Get("GCC53_BIN"))
+	}
+
+	overrides := env.Overrides()
+	if len(overrides) != 5 || overrides[0] != "HOME" || overrides[3] != "PATH" {
+		t.Errorf("Invalid overrides: %v", overrides)
+	}
+}
+
+func TestNewBuildEnvClean(t *testing.T) {
+	defer os.Unsetenv("CLOBBER_TEST_STRAY")
+	defer os.Unsetenv("LC_CLOBBER_TEST")
+	os.Setenv("CLOBBER_TEST_STRAY", "stray")
+	os.Setenv("LC_CLOBBER_TEST", "allowed")
+
+	env := NewBuildEnv("XCODE8", true)
+	if len(env.Get("CLOBBER_TEST_STRAY")) > 0 {
+		t.Errorf("Stray host variables should not be kept in clean mode")
+	}
+	if env.Get("LC_CLOBBER_TEST") != "allowed" {
+		t.Errorf("Allowlisted host variables should be kept in clean mode")
+	}
+	if len(env.Get("PATH")) == 0 || env.Get("HOME") != GetClobberPath() {
+		t.Errorf("Required variables are missing in clean mode")
+	}
+	for _, variable := range env.Environ() {
+		if strings.HasPrefix(variable, "CLOBBER_TEST_STRAY=") {
+			t.Errorf("Stray host variable found in environment: %s", variable)
+		}
+	}
+}
+
+func TestBuildEnvScript(t *testing.T) {
+	defer os.Unsetenv("CLOBBER_TEST_STRAY")
+	os.Setenv("CLOBBER_TEST_STRAY", "stray")
+
+	script := NewBuildEnv("XCODE8", false).Script()
+	if !strings.Contains(script, "export HOME="+ShellQuote(GetClobberPath())+"\n") {
+		t.Errorf("Script does not export HOME:\n%s", script)
+	}
+	if strings.Contains(script, "unset ") {
+		t.Errorf("Script should not unset variables by default:\n%s", script)
+	}
+
+	script = NewBuildEnv("XCODE8", true).Script()
+	if !strings.Contains(script, "unset CLOBBER_TEST_STRAY\n") {
+		t.Errorf("Script does not unset stray variables in clean mode:\n%s", script)
+	}
+}
+
+func TestBuildEnvString(t *testing.T) {
+	env := NewBuildEnv("XCODE8", true)
+	env.Set("GITHUB_API_TOKEN", "hunter2")
+	env.Set("AWS_SECRET_ACCESS_KEY", "hunter3")
+	env.Set("CLOBBER_TEST_VISIBLE", "visible")
+
+	output := env.String()
+	if strings.Contains(output, "hunter") {
+		t.Errorf("Secrets should be redacted: %s", output)
+	}
+	if !strings.Contains(output, "GITHUB_API_TOKEN=[redacted]") || !strings.Contains(output, "CLOBBER_TEST_VISIBLE=visible") {
+		t.Errorf("Variables should still be listed: %s", output)
+	}
+	if env.Get("GITHUB_API_TOKEN") != "hunter2" {
+		t.Errorf("Secrets should still be passed to the build")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	quoted := map[string]string{
+		"":              "''",
+		"simple":        "'simple'",
+		"with space":    "'with space'",
+		"it's":          `'it'\''s'`,
+		"$HOME; rm -rf": "'$HOME; rm -rf'",
+	}
+	for value, expected := range quoted {
+		if result := ShellQuote(value); result != expected {
+			t.Errorf("Quoted %s as %s, expected %s", value, result, expected)
+		}
+	}
+}
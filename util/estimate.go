@@ -0,0 +1,121 @@
+package util
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// estimateSamples is the number of previous durations used for estimating a step
+const estimateSamples = 5
+
+// BuildEstimate predicts the duration of build steps from previous runs
+type BuildEstimate struct {
+	// Expected duration of each step
+	steps map[string]time.Duration
+
+	// Steps (in order) of the most recent comparable successful run
+	plan []StepInfo
+}
+
+// NewBuildEstimate creates an estimate from the runs using the same toolchain,
+// returning nil if there are none. The steps of the most recent successful run
+// with the same flags (or any flags, if there's none) are used as the plan.
+func NewBuildEstimate(records []*RunRecord, toolchain string, flags []string) *BuildEstimate {
+	samples := make(map[string][]time.Duration)
+	var plan, fallbackPlan []StepInfo
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Toolchain != toolchain {
+			continue
+		}
+		for _, step := range record.Steps {
+			if len(samples[step.ID]) < estimateSamples {
+				samples[step.ID] = append(samples[step.ID], step.Duration)
+			}
+		}
+		if record.Outcome != RunSucceeded {
+			continue
+		}
+		if plan == nil && strings.Join(record.Flags, " ") == strings.Join(flags, " ") {
+			plan = record.Steps
+		}
+		if fallbackPlan == nil {
+			fallbackPlan = record.Steps
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	if plan == nil {
+		plan = fallbackPlan
+	}
+
+	estimate := &BuildEstimate{steps: make(map[string]time.Duration)}
+	for id, durations := range samples {
+		estimate.steps[id] = medianDuration(durations)
+	}
+	for _, step := range plan {
+		estimate.plan = append(estimate.plan, StepInfo{ID: step.ID, Title: step.Title, Duration: estimate.steps[step.ID]})
+	}
+	return estimate
+}
+
+// Step returns the expected duration of a step
+func (estimate *BuildEstimate) Step(id string) (time.Duration, bool) {
+	if estimate == nil {
+		return 0, false
+	}
+	duration, ok := estimate.steps[id]
+	return duration, ok
+}
+
+// Progress returns the completed percentage and the expected remaining time of the build,
+// given the completed steps and how long the current step has been running
+func (estimate *BuildEstimate) Progress(completed []StepInfo, current string, elapsed time.Duration) (float64, time.Duration, bool) {
+	if estimate == nil || len(estimate.plan) == 0 {
+		return 0, 0, false
+	}
+	done := make(map[string]bool)
+	for _, step := range completed {
+		done[step.ID] = true
+	}
+
+	var total, remaining time.Duration
+	for _, step := range estimate.plan {
+		expected := step.Duration
+		total += expected
+		if done[step.ID] {
+			continue
+		}
+		if step.ID == current {
+			if elapsed < expected {
+				remaining += expected - elapsed
+			}
+			continue
+		}
+		remaining += expected
+	}
+	if total <= 0 {
+		return 0, 0, false
+	}
+
+	// Never claim to be done before we actually are
+	percent := 100 * float64(total-remaining) / float64(total)
+	if percent > 99 {
+		percent = 99
+	}
+	return percent, remaining, true
+}
+
+// medianDuration returns the median of a list of durations, which is less
+// affected by the occasional unusually slow (or cached) step than the mean
+func medianDuration(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
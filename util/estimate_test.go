@@ -0,0 +1,69 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildEstimate(t *testing.T) {
+	newRecord := func(toolchain string, outcome string, flags []string, steps ...StepInfo) *RunRecord {
+		return &RunRecord{Toolchain: toolchain, Outcome: outcome, Flags: flags, Steps: steps}
+	}
+	step := func(id string, minutes int) StepInfo {
+		return StepInfo{ID: id, Duration: time.Duration(minutes) * time.Minute}
+	}
+	records := []*RunRecord{
+		newRecord("XCODE8", RunSucceeded, nil, step("update", 1), step("build", 10), step("installer", 4)),
+		newRecord("XCODE8", RunSucceeded, nil, step("update", 3), step("build", 14), step("installer", 6)),
+		newRecord("GCC53", RunSucceeded, nil, step("update", 30), step("build", 30)),
+		newRecord("XCODE8", RunFailed, nil, step("update", 2)),
+		newRecord("XCODE8", RunSucceeded, []string{"-b"}, step("build", 12)),
+	}
+
+	if estimate := NewBuildEstimate(records, "CLANG38", nil); estimate != nil {
+		t.Errorf("Expected no estimate without comparable runs, got %v", estimate)
+	}
+
+	estimate := NewBuildEstimate(records, "XCODE8", nil)
+	if duration, ok := estimate.Step("update"); !ok || duration != 2*time.Minute {
+		t.Errorf("Invalid update estimate: %s", duration)
+	}
+	if duration, ok := estimate.Step("build"); !ok || duration != 12*time.Minute {
+		t.Errorf("Invalid build estimate: %s", duration)
+	}
+	if _, ok := estimate.Step("missing"); ok {
+		t.Error("Expected no estimate for an unknown step")
+	}
+
+	// The plan (update, build and installer) is expected to take 19 minutes
+	tests := []struct {
+		completed []StepInfo
+		current   string
+		elapsed   time.Duration
+		percent   float64
+		remaining time.Duration
+	}{
+		{nil, "update", 0, 0, 19 * time.Minute},
+		{[]StepInfo{step("update", 2)}, "build", 6 * time.Minute, 100 * 8 / 19.0, 11 * time.Minute},
+		{[]StepInfo{step("update", 2)}, "build", 20 * time.Minute, 100 * 14 / 19.0, 5 * time.Minute},
+		{[]StepInfo{step("update", 2), step("build", 12)}, "installer", 10 * time.Minute, 99, 0},
+	}
+	for _, test := range tests {
+		percent, remaining, ok := estimate.Progress(test.completed, test.current, test.elapsed)
+		if !ok || percent != test.percent || remaining != test.remaining {
+			t.Errorf("Invalid progress for %s after %s: %.1f%%, %s remaining", test.current, test.elapsed, percent, remaining)
+		}
+	}
+
+	// Runs with the same flags are preferred for the plan
+	estimate = NewBuildEstimate(records, "XCODE8", []string{"-b"})
+	if percent, remaining, ok := estimate.Progress(nil, "build", 6*time.Minute); !ok || percent != 50 || remaining != 6*time.Minute {
+		t.Errorf("Invalid progress with flags: %.1f%%, %s remaining", percent, remaining)
+	}
+
+	// A missing estimate should never break anything
+	var missing *BuildEstimate
+	if _, _, ok := missing.Progress(nil, "build", 0); ok {
+		t.Error("Expected no progress without an estimate")
+	}
+}
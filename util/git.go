@@ -0,0 +1,121 @@
+package util
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CloverRepository is the upstream Clover repository on GitHub
+const CloverRepository = "CloverHackyColor/CloverBootloader"
+
+// CloverRepositoryURL is the upstream Clover repository git URL
+const CloverRepositoryURL = "https://github.com/" + CloverRepository
+
+// LatestReleaseRevision is a revision alias for the latest upstream GitHub release
+const LatestReleaseRevision = "latest-release"
+
+// LatestTagRevision is a revision alias for the most recently created tag
+const LatestTagRevision = "latest-tag"
+
+// Tag is a git tag and its creation date
+type Tag struct {
+	Name string
+	Date time.Time
+}
+
+// ResolveRevision resolves a branch name, tag, commit SHA or one of the
+// revision aliases to a full commit SHA in the supplied git repository
+func ResolveRevision(repoPath string, revision string) (string, error) {
+	// Resolve the revision aliases to actual tags first
+	switch revision {
+	case LatestReleaseRevision:
+		release, err := GetLatestGitHubRelease(CloverRepository)
+		if err != nil {
+			return "", errors.New("Failed to get the latest Clover release: " + err.Error())
+		}
+		revision = release.TagName
+	case LatestTagRevision:
+		tags, err := GetTags(repoPath, 1)
+		if err != nil {
+			return "", err
+		}
+		if len(tags) == 0 {
+			return "", errors.New("Failed to resolve " + LatestTagRevision + ": no tags found")
+		}
+		revision = tags[0].Name
+	}
+
+	// Prefer remote branches over local ones (as they're the most up to date),
+	// then fall back to tags and finally anything else git understands (eg. SHAs)
+	candidates := []string{
+		"refs/remotes/origin/" + revision,
+		"refs/heads/" + revision,
+		"refs/tags/" + revision,
+		revision,
+	}
+	for _, candidate := range candidates {
+		if sha, err := runGit(repoPath, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return sha, nil
+		}
+	}
+
+	return "", errors.New("Failed to resolve revision '" + revision + "': no matching branch, tag or commit")
+}
+
+// DescribeRevision returns the full commit SHA and the "git describe"
+// output for a revision in the supplied git repository
+func DescribeRevision(repoPath string, revision string) (string, string, error) {
+	sha, err := runGit(repoPath, "rev-parse", "--verify", revision+"^{commit}")
+	if err != nil {
+		return "", "", err
+	}
+	describe, err := runGit(repoPath, "describe", "--tags", "--always", sha)
+	if err != nil {
+		return "", "", err
+	}
+	return sha, describe, nil
+}
+
+// GetTags returns the most recently created tags (newest first),
+// limited to count tags (or all tags if count is zero)
+func GetTags(repoPath string, count int) ([]Tag, error) {
+	args := []string{"for-each-ref", "--sort=-creatordate", "--format=%(refname:short)%09%(creatordate:iso-strict)"}
+	if count > 0 {
+		args = append(args, "--count="+strconv.Itoa(count))
+	}
+	args = append(args, "refs/tags")
+	output, err := runGit(repoPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []Tag
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[1])
+		tags = append(tags, Tag{Name: fields[0], Date: date})
+	}
+
+	return tags, nil
+}
+
+// runGit runs git with the supplied arguments in a repository,
+// returning its trimmed output
+func runGit(repoPath string, args ...string) (string, error) {
+	gitCommand := exec.Command("git", args...)
+	gitCommand.Dir = repoPath
+	output, err := gitCommand.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", errors.New("git " + args[0] + " failed: " + strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
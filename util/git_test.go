@@ -0,0 +1,100 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestResolveRevision(t *testing.T) {
+	repoPath := createTestRepository(t)
+	defer os.RemoveAll(repoPath)
+
+	headSHA, _, err := DescribeRevision(repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("Failed to describe HEAD: %s", err)
+	}
+	firstSHA, firstDescribe, err := DescribeRevision(repoPath, "v1.0")
+	if err != nil {
+		t.Fatalf("Failed to describe v1.0: %s", err)
+	}
+	if firstDescribe != "v1.0" {
+		t.Errorf("Invalid describe for v1.0: %s", firstDescribe)
+	}
+
+	revisions := map[string]string{
+		"master":          headSHA,
+		"v1.0":            firstSHA,
+		"v2.0":            headSHA,
+		firstSHA:          firstSHA,
+		firstSHA[:10]:     firstSHA,
+		LatestTagRevision: headSHA,
+	}
+	for revision, expectedSHA := range revisions {
+		sha, err := ResolveRevision(repoPath, revision)
+		if err != nil {
+			t.Errorf("Failed to resolve %s: %s", revision, err)
+		} else if sha != expectedSHA {
+			t.Errorf("Resolved %s to %s, expected %s", revision, sha, expectedSHA)
+		}
+	}
+
+	if _, err := ResolveRevision(repoPath, "does-not-exist"); err == nil {
+		t.Errorf("Failed to detect an invalid revision")
+	}
+}
+
+func TestGetTags(t *testing.T) {
+	repoPath := createTestRepository(t)
+	defer os.RemoveAll(repoPath)
+
+	tags, err := GetTags(repoPath, 0)
+	if err != nil {
+		t.Fatalf("Failed to get tags: %s", err)
+	}
+	if len(tags) != 2 || tags[0].Name != "v2.0" || tags[1].Name != "v1.0" {
+		t.Errorf("Invalid tags: %v", tags)
+	}
+	if tags[0].Date.IsZero() {
+		t.Errorf("Failed to parse tag date")
+	}
+
+	tags, err = GetTags(repoPath, 1)
+	if err != nil {
+		t.Fatalf("Failed to get tags: %s", err)
+	}
+	if len(tags) != 1 {
+		t.Errorf("Failed to limit tags: %v", tags)
+	}
+}
+
+// createTestRepository creates a git repository with two tagged commits
+func createTestRepository(t *testing.T) string {
+	repoPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+
+	commands := [][]string{
+		{"init", "-q"},
+		{"checkout", "-q", "-b", "master"},
+		{"commit", "-q", "--allow-empty", "-m", "First"},
+		{"tag", "-a", "-m", "First", "v1.0"},
+		{"commit", "-q", "--allow-empty", "-m", "Second"},
+		{"tag", "-a", "-m", "Second", "v2.0"},
+	}
+	for i, args := range commands {
+		gitCommand := exec.Command("git", append([]string{"-c", "user.name=Clobber", "-c", "user.email=clobber@localhost"}, args...)...)
+		gitCommand.Dir = repoPath
+		// Make sure the tags get different creation dates
+		gitCommand.Env = append(os.Environ(), fmt.Sprintf("GIT_COMMITTER_DATE=2020-01-%02dT00:00:00Z", i+1))
+		if output, err := gitCommand.CombinedOutput(); err != nil {
+			os.RemoveAll(repoPath)
+			t.Fatalf("Failed to run git %v: %s\n%s", args, err, output)
+		}
+	}
+
+	return repoPath
+}
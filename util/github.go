@@ -0,0 +1,64 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Release is a GitHub release
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// GetGitHubReleases returns the most recent releases (newest first)
+// for a GitHub repository (eg. "CloverHackyColor/CloverBootloader")
+func GetGitHubReleases(repository string, count int) ([]Release, error) {
+	var releases []Release
+	if err := getGitHubAPI("/repos/"+repository+"/releases?per_page="+strconv.Itoa(count), &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// GetLatestGitHubRelease returns the latest (non-prerelease) release
+// for a GitHub repository
+func GetLatestGitHubRelease(repository string) (*Release, error) {
+	release := &Release{}
+	if err := getGitHubAPI("/repos/"+repository+"/releases/latest", release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// getGitHubAPI requests an endpoint from the GitHub API and decodes
+// the JSON response, authenticating with GITHUB_API_TOKEN if it's set
+func getGitHubAPI(endpoint string, result interface{}) error {
+	req, err := http.NewRequest("GET", "https://api.github.com"+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if len(os.Getenv("GITHUB_API_TOKEN")) > 0 {
+		req.Header.Set("Authorization", "token "+os.Getenv("GITHUB_API_TOKEN"))
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("GitHub API request failed with status: " + resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
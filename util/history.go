@@ -0,0 +1,199 @@
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Possible outcomes of a run
+const (
+	RunSucceeded = "success"
+	RunFailed    = "failed"
+	RunAborted   = "aborted"
+)
+
+// maxErrorLines limits how much of an error (which often contains
+// the full output of a failed command) is stored in the history
+const maxErrorLines = 40
+
+// RunRecord is a single run in the build history
+type RunRecord struct {
+	ID           string     `json:"id"`
+	Workspace    string     `json:"workspace"`
+	Started      time.Time  `json:"started"`
+	Finished     time.Time  `json:"finished"`
+	Revision     string     `json:"revision"`
+	Describe     string     `json:"describe"`
+	SHA          string     `json:"sha"`
+	Toolchain    string     `json:"toolchain"`
+	Flags        []string   `json:"flags"`
+	Outcome      string     `json:"outcome"`
+	FailedStep   string     `json:"failedStep,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	Steps        []StepInfo `json:"steps"`
+	LogFile      string     `json:"logFile"`
+	ArtifactPath string     `json:"artifactPath,omitempty"`
+}
+
+// RunStats summarizes the runs of a single revision range
+type RunStats struct {
+	Range        string
+	Runs         int
+	Successes    int
+	MeanDuration time.Duration
+}
+
+// describeSuffixRegex matches the "-<commits>-g<sha>" and "-dirty" suffixes of git describe
+var describeSuffixRegex = regexp.MustCompile(`(-[0-9]+-g[0-9a-f]+)?(-dirty)?$`)
+
+// GetHistoryPath returns the full path to the build history file
+func GetHistoryPath() string {
+	return GetClobberPath() + "/history.jsonl"
+}
+
+// NewRunID returns the ID of a run started at the given time
+func NewRunID(started time.Time) string {
+	return started.Format("20060102-150405")
+}
+
+// Duration returns how long the run took
+func (record *RunRecord) Duration() time.Duration {
+	if record.Finished.IsZero() {
+		return 0
+	}
+	return record.Finished.Sub(record.Started)
+}
+
+// MarshalJSON adds how long the run took, in seconds (like the step durations and
+// the summary event of the JSON output), so the history can be read without parsing times
+func (record RunRecord) MarshalJSON() ([]byte, error) {
+	type plainRunRecord RunRecord
+	return json.Marshal(struct {
+		plainRunRecord
+		Duration float64 `json:"duration"`
+	}{plainRunRecord(record), record.Duration().Seconds()})
+}
+
+// SetError stores the (truncated) error that ended the run
+func (record *RunRecord) SetError(message string) {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) > maxErrorLines {
+		lines = append([]string{fmt.Sprintf("(%d lines omitted)", len(lines)-maxErrorLines)}, lines[len(lines)-maxErrorLines:]...)
+	}
+	record.Error = strings.Join(lines, "\n")
+}
+
+// AppendRunRecord adds a run to the end of a history file
+func AppendRunRecord(path string, record *RunRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// ReadRunRecords returns all runs in a history file, oldest first,
+// skipping any lines that can't be parsed
+func ReadRunRecords(path string) ([]*RunRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*RunRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		record := &RunRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// FindRunRecord returns the run with the given ID (or a unique prefix of it),
+// or the most recent run if the ID is "latest"
+func FindRunRecord(records []*RunRecord, id string) (*RunRecord, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no runs in the build history")
+	}
+	if id == "latest" {
+		return records[len(records)-1], nil
+	}
+	var matches []*RunRecord
+	for _, record := range records {
+		if record.ID == id {
+			return record, nil
+		}
+		if strings.HasPrefix(record.ID, id) {
+			matches = append(matches, record)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("run '%s' not found", id)
+	} else if len(matches) > 1 {
+		return nil, fmt.Errorf("run '%s' is ambiguous (%d matches)", id, len(matches))
+	}
+	return matches[0], nil
+}
+
+// GetRevisionRange returns the tag a revision is based on (eg. "v2.5k_r5120"
+// for "v2.5k_r5120-3-gabcdef1"), which is used for grouping runs
+func GetRevisionRange(describe string) string {
+	if len(describe) == 0 {
+		return "unknown"
+	}
+	return describeSuffixRegex.ReplaceAllString(describe, "")
+}
+
+// ComputeRunStats returns the success rate and mean duration of successful runs
+// per revision range, ordered by the most recent run of each range
+func ComputeRunStats(records []*RunRecord) []RunStats {
+	statsByRange := make(map[string]*RunStats)
+	lastRun := make(map[string]time.Time)
+	totalDurations := make(map[string]time.Duration)
+	for _, record := range records {
+		revisionRange := GetRevisionRange(record.Describe)
+		stats, ok := statsByRange[revisionRange]
+		if !ok {
+			stats = &RunStats{Range: revisionRange}
+			statsByRange[revisionRange] = stats
+		}
+		stats.Runs++
+		if record.Outcome == RunSucceeded {
+			stats.Successes++
+			totalDurations[revisionRange] += record.Duration()
+		}
+		if record.Started.After(lastRun[revisionRange]) {
+			lastRun[revisionRange] = record.Started
+		}
+	}
+
+	var result []RunStats
+	for revisionRange, stats := range statsByRange {
+		if stats.Successes > 0 {
+			stats.MeanDuration = totalDurations[revisionRange] / time.Duration(stats.Successes)
+		}
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return lastRun[result[i].Range].After(lastRun[result[j].Range])
+	})
+	return result
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunRecords(t *testing.T) {
+	historyFile, err := ioutil.TempFile("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %s", err)
+	}
+	historyFile.Close()
+	defer os.Remove(historyFile.Name())
+
+	started := time.Date(2020, 11, 3, 14, 5, 9, 0, time.Local)
+	for index, outcome := range []string{RunSucceeded, RunFailed} {
+		record := &RunRecord{
+			ID:       NewRunID(started.Add(time.Duration(index) * time.Hour)),
+			Started:  started.Add(time.Duration(index) * time.Hour),
+			Finished: started.Add(time.Duration(index)*time.Hour + 10*time.Minute),
+			Outcome:  outcome,
+			Steps:    []StepInfo{{ID: "build-boot6", Duration: 5 * time.Minute}},
+		}
+		if err := AppendRunRecord(historyFile.Name(), record); err != nil {
+			t.Fatalf("Failed to append run record: %s", err)
+		}
+	}
+	// The history uses the same camelCase keys as the build manifest, with durations in seconds
+	data, _ := ioutil.ReadFile(historyFile.Name())
+	for _, expected := range []string{`"id":"20201103-140509"`, `"outcome":"success"`, `"steps":[{"id":"build-boot6","title":"","duration":300}]`, `"duration":600}`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in the history file: %s", expected, data)
+		}
+	}
+
+	// Broken lines (eg. from a crash while writing) should be skipped
+	file, _ := os.OpenFile(historyFile.Name(), os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("{\"id\": \"broken\n")
+	file.Close()
+
+	records, err := ReadRunRecords(historyFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to read run records: %s", err)
+	}
+	if len(records) != 2 || records[0].ID != "20201103-140509" || records[1].Outcome != RunFailed {
+		t.Fatalf("Invalid run records: %v", records)
+	}
+	if records[0].Duration() != 10*time.Minute || records[0].Steps[0].Duration != 5*time.Minute {
+		t.Errorf("Invalid durations: %s, %s", records[0].Duration(), records[0].Steps[0].Duration)
+	}
+
+	if record, err := FindRunRecord(records, "latest"); err != nil || record != records[1] {
+		t.Errorf("Failed to find the latest run: %v (%v)", record, err)
+	}
+	if record, err := FindRunRecord(records, "20201103-14"); err != nil || record != records[0] {
+		t.Errorf("Failed to find run by prefix: %v (%v)", record, err)
+	}
+	if _, err := FindRunRecord(records, "20201103"); err == nil {
+		t.Error("Expected an ambiguous prefix to fail")
+	}
+	if _, err := FindRunRecord(nil, "latest"); err == nil {
+		t.Error("Expected an empty history to fail")
+	}
+}
+
+func TestSetError(t *testing.T) {
+	record := &RunRecord{}
+	var lines []string
+	for index := 0; index < 100; index++ {
+		lines = append(lines, "line")
+	}
+	record.SetError(strings.Join(lines, "\n"))
+	errorLines := strings.Split(record.Error, "\n")
+	if len(errorLines) != maxErrorLines+1 || errorLines[0] != "(60 lines omitted)" {
+		t.Errorf("Invalid error: %v", errorLines[:2])
+	}
+}
+
+func TestComputeRunStats(t *testing.T) {
+	started := time.Date(2020, 11, 3, 14, 0, 0, 0, time.Local)
+	newRecord := func(hours int, describe string, outcome string, minutes int) *RunRecord {
+		return &RunRecord{
+			Started:  started.Add(time.Duration(hours) * time.Hour),
+			Finished: started.Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute),
+			Describe: describe,
+			Outcome:  outcome,
+		}
+	}
+	records := []*RunRecord{
+		newRecord(0, "v2.5k_r5119", RunSucceeded, 10),
+		newRecord(1, "v2.5k_r5120-2-gabcdef1", RunSucceeded, 10),
+		newRecord(2, "v2.5k_r5120-3-g1234567-dirty", RunFailed, 1),
+		newRecord(3, "v2.5k_r5120", RunSucceeded, 20),
+	}
+
+	stats := ComputeRunStats(records)
+	if len(stats) != 2 {
+		t.Fatalf("Invalid stats: %v", stats)
+	}
+	if stats[0].Range != "v2.5k_r5120" || stats[0].Runs != 3 || stats[0].Successes != 2 || stats[0].MeanDuration != 15*time.Minute {
+		t.Errorf("Invalid stats for v2.5k_r5120: %+v", stats[0])
+	}
+	if stats[1].Range != "v2.5k_r5119" || stats[1].Runs != 1 || stats[1].Successes != 1 || stats[1].MeanDuration != 10*time.Minute {
+		t.Errorf("Invalid stats for v2.5k_r5119: %+v", stats[1])
+	}
+}
+
+func TestGetRevisionRange(t *testing.T) {
+	tests := map[string]string{
+		"v2.5k_r5120":                  "v2.5k_r5120",
+		"v2.5k_r5120-3-gabcdef1":       "v2.5k_r5120",
+		"v2.5k_r5120-3-gabcdef1-dirty": "v2.5k_r5120",
+		"5120":                         "5120",
+		"":                             "unknown",
+	}
+	for describe, expected := range tests {
+		if revisionRange := GetRevisionRange(describe); revisionRange != expected {
+			t.Errorf("Invalid revision range for %s: %s", describe, revisionRange)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LockInfo describes the process holding a lock
+type LockInfo struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	Command string    `json:"command"`
+}
+
+// Lock is an advisory lock file, which is held by a single process at a time
+// (using flock, so the lock goes away with the process holding it)
+type Lock struct {
+	path string
+	file *os.File
+	info LockInfo
+}
+
+// LockedError is returned when a lock is held by another running process
+type LockedError struct {
+	Path   string
+	Holder LockInfo
+}
+
+// Error describes the holder of the lock
+func (err *LockedError) Error() string {
+	if err.Holder.PID == 0 {
+		return "locked by another process"
+	}
+	return fmt.Sprintf("locked by PID %d (started %s, command: %s)", err.Holder.PID, err.Holder.Started.Format("2006-01-02 15:04:05"), err.Holder.Command)
+}
+
+// lockAttempts is how many times a held lock is tried before giving up
+const lockAttempts = 5
+
+// GetLockPath returns the full path to the lock file of the active workspace
+func GetLockPath() string {
+	return GetClobberPath() + "/clobber.lock"
+}
+
+// AcquireLock locks the lock file at the supplied path, returning a *LockedError
+// if another running process holds it (lock files left behind by dead processes are reused)
+func AcquireLock(path string) (*Lock, error) {
+	lock := &Lock{path: path}
+	lock.info = LockInfo{
+		PID:     os.Getpid(),
+		Started: time.Now(),
+		Command: strings.Join(os.Args, " "),
+	}
+	lockData, err := json.Marshal(lock.info)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			file.Close()
+			if err != syscall.EWOULDBLOCK {
+				return nil, err
+			}
+			// IsLocked holds a shared lock for a moment, so give it a chance to let go
+			if attempt < lockAttempts {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			// The holder may still be writing its information, so it's optional
+			holder, _ := ReadLock(path)
+			if holder == nil {
+				holder = &LockInfo{}
+			}
+			return nil, &LockedError{Path: path, Holder: *holder}
+		}
+
+		// The previous holder removes the file while releasing it, in which
+		// case the lock we got is on a file nobody else will ever look at
+		if !isSameFile(file, path) {
+			file.Close()
+			continue
+		}
+
+		if err := file.Truncate(0); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if _, err := file.WriteAt(lockData, 0); err != nil {
+			file.Close()
+			return nil, err
+		}
+		lock.file = file
+		return lock, nil
+	}
+}
+
+// isSameFile returns true if the open file is still the one at the supplied path
+func isSameFile(file *os.File, path string) bool {
+	openInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(openInfo, pathInfo)
+}
+
+// Release removes the lock file and unlocks it (releasing it more than once is fine)
+func (lock *Lock) Release() error {
+	if lock.file == nil {
+		return nil
+	}
+	// Remove the file while still holding the lock, so nobody locks it in between
+	err := os.Remove(lock.path)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if closeErr := lock.file.Close(); err == nil {
+		err = closeErr
+	}
+	lock.file = nil
+	return err
+}
+
+// IsLocked returns the holder of a lock file, if a running process holds it
+func IsLocked(path string) (*LockInfo, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	// A shared lock can only be taken if nobody holds the lock
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		return nil, false
+	}
+	holder, err := ReadLock(path)
+	if err != nil {
+		holder = &LockInfo{}
+	}
+	return holder, true
+}
+
+// ReadLock returns information on the current holder of a lock file
+func ReadLock(path string) (*LockInfo, error) {
+	lockData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info := &LockInfo{}
+	if err := json.Unmarshal(lockData, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	lockDir, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(lockDir)
+	lockPath := lockDir + "/clobber.lock"
+
+	lock, err := AcquireLock(lockPath)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %s", err)
+	}
+
+	holder, err := ReadLock(lockPath)
+	if err != nil {
+		t.Fatalf("Failed to read lock: %s", err)
+	}
+	if holder.PID != os.Getpid() || len(holder.Command) == 0 || holder.Started.IsZero() {
+		t.Errorf("Invalid lock holder: %+v", holder)
+	}
+
+	// Acquiring a held lock should fail with the holder information
+	if _, err := AcquireLock(lockPath); err == nil {
+		t.Errorf("Acquired a lock that was already held")
+	} else if lockedErr, ok := err.(*LockedError); !ok || lockedErr.Holder.PID != os.Getpid() {
+		t.Errorf("Invalid error for a held lock: %s", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Errorf("Failed to release lock: %s", err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Lock file was not removed when releasing")
+	}
+}
+
+func TestAcquireStaleLock(t *testing.T) {
+	lockDir, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(lockDir)
+	lockPath := lockDir + "/clobber.lock"
+
+	// A lock file left behind (even by a PID that's in use again, or a broken one) isn't held by anyone
+	staleData, _ := json.Marshal(LockInfo{PID: os.Getppid(), Started: time.Now().Add(-time.Hour), Command: "clobber"})
+	for _, data := range [][]byte{staleData, []byte("{")} {
+		if err := ioutil.WriteFile(lockPath, data, 0644); err != nil {
+			t.Fatalf("Failed to write stale lock: %s", err)
+		}
+		if holder, locked := IsLocked(lockPath); locked {
+			t.Errorf("Stale lock should not be held: %+v", holder)
+		}
+
+		lock, err := AcquireLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to replace stale lock: %s", err)
+		}
+		if holder, err := ReadLock(lockPath); err != nil || holder.PID != os.Getpid() {
+			t.Errorf("Stale lock was not replaced: %+v", holder)
+		}
+		if holder, locked := IsLocked(lockPath); !locked || holder.PID != os.Getpid() {
+			t.Errorf("Lock should be held by us: %+v", holder)
+		}
+		lock.Release()
+	}
+}
+
+func TestAcquireLockConcurrently(t *testing.T) {
+	lockDir, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(lockDir)
+	lockPath := lockDir + "/clobber.lock"
+
+	// Each open lock file is locked separately, so goroutines compete like processes do
+	var holders, acquired int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				lock, err := AcquireLock(lockPath)
+				if _, locked := err.(*LockedError); locked {
+					continue
+				} else if err != nil {
+					t.Errorf("Failed to acquire lock: %s", err)
+					return
+				}
+				if atomic.AddInt32(&holders, 1) > 1 {
+					t.Errorf("Lock is held twice")
+				}
+				atomic.AddInt32(&acquired, 1)
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				lock.Release()
+			}
+		}()
+	}
+	wg.Wait()
+	if acquired == 0 {
+		t.Errorf("Lock was never acquired")
+	}
+}
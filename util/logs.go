@@ -0,0 +1,146 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runLogID is the ID of the run currently logging to its own log file
+var runLogID string
+
+// diagnosticRegex matches compiler/linker errors and warnings, failed make targets and our own errors
+var diagnosticRegex = regexp.MustCompile(`(?i)((^|[\s:"])(fatal error|error|warning)( [a-z]*[0-9]+)?:|make(\[[0-9]+\])?: \*\*\*|undefined (reference|symbol))`)
+
+// SetRunLog switches GetLogFilePath to the log file of a run
+func SetRunLog(runID string) {
+	runLogID = runID
+}
+
+// GetRunLogPath returns the full path to the log file of a run
+func GetRunLogPath(runID string) string {
+	return GetLogsPath() + "/" + runID + ".log"
+}
+
+// GetStepLogPath returns the full path to the log file of a single step of a run
+func GetStepLogPath(runID string, stepID string) string {
+	return GetLogsPath() + "/" + runID + "/" + stepID + ".log"
+}
+
+// LockRunLog locks the open log file of a run until it's closed (or the process exits),
+// which tells others that the run is still going (see IsRunLogLocked)
+func LockRunLog(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// IsRunLogLocked returns true if the run is still going
+func IsRunLogLocked(runID string) bool {
+	_, locked := IsLocked(GetRunLogPath(runID))
+	return locked
+}
+
+// ListRunLogs returns the IDs of all runs with a log file, oldest first
+func ListRunLogs() ([]string, error) {
+	matches, err := filepath.Glob(GetLogsPath() + "/*.log")
+	if err != nil {
+		return nil, err
+	}
+	var runIDs []string
+	for _, match := range matches {
+		runID := strings.TrimSuffix(filepath.Base(match), ".log")
+		if runID != "clobber" {
+			runIDs = append(runIDs, runID)
+		}
+	}
+	// Run IDs start with a timestamp, so they sort chronologically
+	sort.Strings(runIDs)
+	return runIDs, nil
+}
+
+// ListStepLogs returns the IDs of all steps of a run with a log file
+func ListStepLogs(runID string) ([]string, error) {
+	fileInfos, err := ioutil.ReadDir(GetLogsPath() + "/" + runID)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var stepIDs []string
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() && strings.HasSuffix(fileInfo.Name(), ".log") {
+			stepIDs = append(stepIDs, strings.TrimSuffix(fileInfo.Name(), ".log"))
+		}
+	}
+	return stepIDs, nil
+}
+
+// PruneRunLogs removes the log files (including step logs) of runs older than maxAge
+func PruneRunLogs(maxAge time.Duration) error {
+	runIDs, err := ListRunLogs()
+	if err != nil {
+		return err
+	}
+	for _, runID := range runIDs {
+		info, err := os.Stat(GetRunLogPath(runID))
+		if err != nil || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(GetRunLogPath(runID)); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(GetLogsPath() + "/" + runID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsDiagnosticLine returns true if a log line looks like an error or a warning
+func IsDiagnosticLine(line string) bool {
+	return diagnosticRegex.MatchString(line)
+}
+
+// FollowFile calls handle for every line of a file. Once the end of the file
+// is reached, it keeps waiting for new lines until done returns true.
+func FollowFile(path string, done func() bool, handle func(line string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	partialLine := ""
+	finishing := false
+	for {
+		line, err := reader.ReadString('\n')
+		partialLine += line
+		if err == io.EOF {
+			// Read once more after we're done, so nothing written in the meantime is missed
+			if finishing {
+				if len(partialLine) > 0 {
+					handle(partialLine)
+				}
+				return nil
+			}
+			if done() {
+				finishing = true
+				continue
+			}
+			time.Sleep(500 * time.Millisecond)
+			continue
+		} else if err != nil {
+			return err
+		}
+		finishing = false
+		handle(strings.TrimSuffix(partialLine, "\n"))
+		partialLine = ""
+	}
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunLogs(t *testing.T) {
+	rootPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(rootPath)
+	defer os.Setenv("CLOBBER_HOME", os.Getenv("CLOBBER_HOME"))
+	os.Setenv("CLOBBER_HOME", rootPath)
+
+	if path := GetLogFilePath(); path != rootPath+"/logs/clobber.log" {
+		t.Errorf("Invalid default log file path: %s", path)
+	}
+	SetRunLog("20201103-140509")
+	if path := GetLogFilePath(); path != rootPath+"/logs/20201103-140509.log" {
+		t.Errorf("Invalid run log file path: %s", path)
+	}
+	SetRunLog("")
+
+	os.MkdirAll(rootPath+"/logs/20201103-140509", 0755)
+	for _, path := range []string{"clobber.log", "20201103-140509.log", "20201101-100000.log", "20201103-140509/build-boot7.log"} {
+		ioutil.WriteFile(rootPath+"/logs/"+path, nil, 0644)
+	}
+	runIDs, err := ListRunLogs()
+	if err != nil || strings.Join(runIDs, ",") != "20201101-100000,20201103-140509" {
+		t.Errorf("Invalid run logs: %v (%v)", runIDs, err)
+	}
+	stepIDs, err := ListStepLogs("20201103-140509")
+	if err != nil || strings.Join(stepIDs, ",") != "build-boot7" {
+		t.Errorf("Invalid step logs: %v (%v)", stepIDs, err)
+	}
+
+	// A run is going for as long as its log file is open
+	file, err := os.OpenFile(GetRunLogPath("20201103-140509"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LockRunLog(file); err != nil || !IsRunLogLocked("20201103-140509") {
+		t.Errorf("Expected the run log to be locked (%v)", err)
+	}
+	file.Close()
+	if IsRunLogLocked("20201103-140509") || IsRunLogLocked("20201101-100000") {
+		t.Error("Expected the run logs to be unlocked")
+	}
+
+	// Only the old run should be pruned
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	os.Chtimes(GetRunLogPath("20201101-100000"), old, old)
+	if err := PruneRunLogs(90 * 24 * time.Hour); err != nil {
+		t.Fatalf("Failed to prune run logs: %s", err)
+	}
+	if runIDs, _ := ListRunLogs(); strings.Join(runIDs, ",") != "20201103-140509" {
+		t.Errorf("Invalid run logs after pruning: %v", runIDs)
+	}
+}
+
+func TestIsDiagnosticLine(t *testing.T) {
+	tests := map[string]bool{
+		"Clover/Library/Foo.c:12:3: error: use of undeclared identifier 'x'":             true,
+		"Clover/Library/Foo.c:40:1: warning: unused variable 'y' [-Wunused]":             true,
+		"ld: warning: directory not found for option '-L/usr/local/lib'":                 true,
+		"make[1]: *** [Foo.obj] Error 1":                                                 true,
+		"make: *** BaseTools/Source/C: No such file or directory.  Stop.":                true,
+		"GenFw: ERROR 3000: Invalid":                                                     true,
+		"Foo.c : error 7000: Failed to execute command":                                  true,
+		"Undefined symbols for architecture x86_64:":                                     true,
+		"Building ... Clover/Library/ErrorLib/ErrorLib.inf [X64]":                        false,
+		"\"clang\" -Werror -Wno-error=unused -c -o Foo.obj Foo.c":                        false,
+		"- Done - (0 warnings, 0 errors)":                                                false,
+		"Error: Failure detected, aborting":                                              true,
+		`time="2020-11-03T14:05:09Z" level=fatal msg="Error: Failure detected, aborting`: true,
+		`time="2020-11-03T14:05:09Z" level=warning msg="Running command"`:                false,
+	}
+	for line, expected := range tests {
+		if IsDiagnosticLine(line) != expected {
+			t.Errorf("Expected %t for %q", expected, line)
+		}
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	logFile, err := ioutil.TempFile("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary file: %s", err)
+	}
+	defer os.Remove(logFile.Name())
+	defer logFile.Close()
+	logFile.WriteString("first\nsecond\nthi")
+
+	// Keep writing while following, then finish without a trailing newline
+	finished := make(chan bool, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		logFile.WriteString("rd\nfourth")
+		finished <- true
+	}()
+
+	var lines []string
+	isFinished := false
+	done := func() bool {
+		select {
+		case isFinished = <-finished:
+		default:
+		}
+		return isFinished
+	}
+	if err := FollowFile(logFile.Name(), done, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatalf("Failed to follow file: %s", err)
+	}
+	if strings.Join(lines, ",") != "first,second,third,fourth" {
+		t.Errorf("Invalid lines: %v", lines)
+	}
+}
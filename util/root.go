@@ -9,7 +9,8 @@ import (
 	"os"
 	"os/exec"
 	"path"
-	"regexp"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,11 +20,12 @@ import (
 	homedir "github.com/mitchellh/go-homedir"
 )
 
-// TODO: Should we create a "GetLogPath" function that returns a STATIC log filename/path,
-//       with the current date/time appended to it, so it's the same file per each run?
-
-// GetLogFilePath returns the full path to the current log file
+// GetLogFilePath returns the full path to the current log file,
+// which is the run's own log file during a build (see SetRunLog)
 func GetLogFilePath() string {
+	if len(runLogID) > 0 {
+		return GetRunLogPath(runLogID)
+	}
 	return GetLogsPath() + "/clobber.log"
 }
 
@@ -32,8 +34,26 @@ func GetLogsPath() string {
 	return GetClobberPath() + "/logs"
 }
 
+// cloverPathOverride replaces the managed Clover path when set
+var cloverPathOverride string
+
+// SetCloverPath overrides the path returned by GetCloverPath,
+// which is used for building an existing Clover checkout in place
+func SetCloverPath(path string) {
+	cloverPathOverride = path
+}
+
+// IsExternalCloverPath returns true if Clover is an existing
+// checkout, which Clobber doesn't manage (see SetCloverPath)
+func IsExternalCloverPath() bool {
+	return len(cloverPathOverride) > 0
+}
+
 // GetCloverPath returns the full path to Clover
 func GetCloverPath() string {
+	if IsExternalCloverPath() {
+		return cloverPathOverride
+	}
 	return GetSourcePath() + "/Clover"
 	//return GetEdkPath() + "/Clover"
 }
@@ -49,7 +69,21 @@ func GetSourcePath() string {
 }
 
 // GetClobberPath returns the full path to the Clobber directory
+// of the active workspace (see SetWorkspace)
 func GetClobberPath() string {
+	return GetWorkspacePath(GetWorkspace())
+}
+
+// GetRootPath returns the full path to the Clobber root directory,
+// which is either CLOBBER_HOME (if set) or ~/.clobber
+func GetRootPath() string {
+	if clobberHome := os.Getenv("CLOBBER_HOME"); len(clobberHome) > 0 {
+		rootPath, err := filepath.Abs(clobberHome)
+		if err != nil {
+			log.Fatal("GetRootPath failed with error: ", err)
+		}
+		return rootPath
+	}
 	return GetHomePath() + "/.clobber"
 }
 
@@ -63,91 +97,62 @@ func GetHomePath() string {
 	return home
 }
 
-// GetScorePath returns the path to the highscore file
+// GetScorePath returns the path to the old single score highscore file,
+// which is only used for migrating to the highscore table
 func GetScorePath() string {
-	return GetClobberPath() + "/.score"
+	return GetRootPath() + "/.score"
 }
 
-// GetVersionDump returns a multi-line string containing the versions/commits
-// for important dependencies and environments, like OS and LLVM versions
-func GetVersionDump() string {
-	// Start with an empty result string
-	var result = ""
+// GetHighscoresPath returns the path to the highscore table,
+// which is shared between all workspaces
+func GetHighscoresPath() string {
+	return GetRootPath() + "/highscores.json"
+}
 
-	// Get macOS version
-	macosVersionOutput, macosVersionErr := exec.Command("sw_vers", "-productVersion").CombinedOutput()
-	if macosVersionErr != nil {
-		log.Fatal("Failed to get macOS version:", macosVersionErr, string(macosVersionOutput))
-	}
-	result += "macOS " + string(macosVersionOutput) + "\n"
+// GetSnakeConfigPath returns the path to the config file of the game,
+// which is shared between all workspaces
+func GetSnakeConfigPath() string {
+	return GetRootPath() + "/snake.json"
+}
 
-	// Get Xcode version
-	xcodeVersionOutput, xcodeVersionErr := exec.Command("xcodebuild", "-version").CombinedOutput()
-	if xcodeVersionErr != nil {
-		log.Fatal("Failed to get Xcode version:", xcodeVersionErr, string(xcodeVersionOutput))
-	}
-	xcodeVersionSplit := strings.Split(string(xcodeVersionOutput), "\n")
-	xcodeVersion := xcodeVersionSplit[0]
-	result += string(xcodeVersion) + "\n"
-
-	// Get clang version
-	clangVersionOutput, clangVersionErr := exec.Command("clang", "-v").CombinedOutput()
-	if clangVersionErr != nil {
-		log.Fatal("Failed to get clang version:", clangVersionErr, string(clangVersionOutput))
-	}
-	clangVersionSplit := strings.Split(string(clangVersionOutput), "\n")
-	clangVersion := clangVersionSplit[0]
-	result += string(clangVersion) + "\n"
-
-	// Get Clover version
-	// getCloverVersionCommand := exec.Command("svn", "info", "--show-item", "revision")
-	getCloverVersionCommand := exec.Command("bash", "-c", "git describe --tags")
-	getCloverVersionCommand.Dir = GetCloverPath()
-	cloverVersionOutput, cloverVersionErr := getCloverVersionCommand.CombinedOutput()
-	if cloverVersionErr != nil {
-		log.Fatal("Failed to get Clover version:", cloverVersionErr, string(cloverVersionOutput))
-	}
-	cloverVersion := strings.Replace(string(cloverVersionOutput), "\n", "", -1)
-	result += "Clover (" + string(cloverVersion) + ")\n"
+// GetReplaysPath returns the path to the directory with the replays
+// of the best snake games, which is shared between all workspaces
+func GetReplaysPath() string {
+	return GetRootPath() + "/replays"
+}
 
-	// Check if the external packages directory exists
-	if _, extPackagePathExistsErr := os.Stat(GetExtPath()); !os.IsNotExist(extPackagePathExistsErr) {
-		if extPackagePathExistsErr != nil {
-			log.Fatal("Failed to check if external packages directory exists:", extPackagePathExistsErr)
-		} else {
-			// Loop through each external package and get their versions/commits,
-			// finally appending them to the result string
-			extPackagePaths, listExtPackagesErr := ioutil.ReadDir(GetExtPath())
-			if listExtPackagesErr != nil {
-				log.Fatal("Failed to list external packages:", listExtPackagesErr)
-			}
-			for _, extPackage := range extPackagePaths {
-				getExtPackageVersionCommand := exec.Command("git", "rev-parse", "HEAD")
-				getExtPackageVersionCommand.Dir = GetExtPath() + "/" + extPackage.Name()
-				extPackageVersionOutput, getVersionErr := getExtPackageVersionCommand.CombinedOutput()
-				if getVersionErr != nil {
-					log.Fatal("Failed to get version for external package:", getVersionErr, string(extPackageVersionOutput))
-				}
-
-				// Format the package version
-				extPackageVersionSplit := strings.Split(string(extPackageVersionOutput), "\n")
-				extPackageVersion := extPackageVersionSplit[0]
-
-				// Append the package name and version to the result, ending with a newline
-				result += extPackage.Name() + " (" + string(extPackageVersion) + ")\n"
-			}
-		}
+// GetSnakeMapsPath returns the path to the directory with custom
+// snake maps, which is shared between all workspaces
+func GetSnakeMapsPath() string {
+	return GetRootPath() + "/maps"
+}
+
+// GetModifiedFiles returns the paths of all modified, added, deleted
+// and untracked files in the git repository at the supplied path
+func GetModifiedFiles(repoPath string) ([]string, error) {
+	statusCommand := exec.Command("git", "status", "--porcelain", "-z")
+	statusCommand.Dir = repoPath
+	statusOutput, statusErr := statusCommand.Output()
+	if statusErr != nil {
+		return nil, statusErr
 	}
 
-	// Remove empty lines from the result (if any)
-	removeEmptyLinesRegex, removeEmptyLinesErr := regexp.Compile("\n\n")
-	if removeEmptyLinesErr != nil {
-		log.Fatal("Failed to remove empty lines:", removeEmptyLinesErr)
+	// Each record is formatted as "XY path" (with the path as is, not quoted) and ends with a NUL,
+	// with renames and copies followed by an extra record with the path they came from
+	var modifiedFiles []string
+	records := strings.Split(string(statusOutput), "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(record) <= 3 {
+			continue
+		}
+		modifiedFiles = append(modifiedFiles, record[3:])
+		if record[0] == 'R' || record[0] == 'C' {
+			i++
+		}
 	}
-	result = removeEmptyLinesRegex.ReplaceAllString(result, "\n")
 
-	// Return the constructed result string
-	return result
+	return modifiedFiles, nil
 }
 
 // StringReplaceFile allows you to replace a string in a file
@@ -189,6 +194,21 @@ func DownloadFile(url string, path string) error {
 	return nil
 }
 
+// WriteFileAtomic writes data to a temporary file next to the path, then renames it in place,
+// so the file is never seen partially written (not even if we crash while writing)
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tempPath := path + "." + strconv.Itoa(os.Getpid()) + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, perm); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
 // CopyFile will copy a single file from the source path
 // to the destination path
 func CopyFile(source string, destination string) error {
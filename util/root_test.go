@@ -3,6 +3,7 @@ package util
 import (
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"testing"
 	"time"
 )
@@ -42,6 +43,82 @@ func TestGetHomePath(t *testing.T) {
 	}
 }
 
+func TestSetCloverPath(t *testing.T) {
+	defer SetCloverPath("")
+
+	if IsExternalCloverPath() {
+		t.Errorf("Clover path should not be external by default")
+	}
+
+	SetCloverPath("/tmp/Clover")
+	if !IsExternalCloverPath() {
+		t.Errorf("Clover path should be external after overriding it")
+	}
+	if path := GetCloverPath(); path != "/tmp/Clover" {
+		t.Errorf("Invalid overridden path: %s", path)
+	}
+}
+
+func TestGetModifiedFiles(t *testing.T) {
+	repoPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(repoPath)
+
+	if _, err := GetModifiedFiles(repoPath); err == nil {
+		t.Errorf("Failed to detect a missing git repository")
+	}
+
+	if output, err := exec.Command("git", "init", repoPath).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to initialize git repository: %s\n%s", err, output)
+	}
+
+	modifiedFiles, err := GetModifiedFiles(repoPath)
+	if err != nil {
+		t.Errorf("Failed to get modified files: %s", err)
+	}
+	if len(modifiedFiles) != 0 {
+		t.Errorf("Clean repository should not have modified files: %v", modifiedFiles)
+	}
+
+	if err := ioutil.WriteFile(repoPath+"/ebuild.sh", []byte("#!/bin/bash\n"), 0644); err != nil {
+		t.Fatalf("Failed to write to file: %s", err)
+	}
+
+	modifiedFiles, err = GetModifiedFiles(repoPath)
+	if err != nil {
+		t.Errorf("Failed to get modified files: %s", err)
+	}
+	if len(modifiedFiles) != 1 || modifiedFiles[0] != "ebuild.sh" {
+		t.Errorf("Failed to list modified files: %v", modifiedFiles)
+	}
+
+	// Renamed files are listed once with their new path, and paths aren't quoted
+	for _, args := range [][]string{
+		{"add", "ebuild.sh"},
+		{"-c", "user.name=Clobber", "-c", "user.email=clobber@example.com", "commit", "-m", "Add ebuild.sh"},
+		{"mv", "ebuild.sh", "build.sh"},
+	} {
+		command := exec.Command("git", args...)
+		command.Dir = repoPath
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to run git %v: %s\n%s", args, err, output)
+		}
+	}
+	if err := ioutil.WriteFile(repoPath+"/Bootlöader config.plist", []byte("<plist/>\n"), 0644); err != nil {
+		t.Fatalf("Failed to write to file: %s", err)
+	}
+
+	modifiedFiles, err = GetModifiedFiles(repoPath)
+	if err != nil {
+		t.Errorf("Failed to get modified files: %s", err)
+	}
+	if len(modifiedFiles) != 2 || modifiedFiles[0] != "build.sh" || modifiedFiles[1] != "Bootlöader config.plist" {
+		t.Errorf("Failed to list renamed and quoted files: %q", modifiedFiles)
+	}
+}
+
 func TestStringReplaceFile(t *testing.T) {
 	file, err := ioutil.TempFile("", "clobber-test")
 	if err != nil {
@@ -84,6 +161,27 @@ func TestStringReplaceFile(t *testing.T) {
 	os.Remove(file.Name())
 }
 
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := dir + "/file.txt"
+	for _, contents := range []string{"first version, which is longer\n", "second\n"} {
+		if err := WriteFileAtomic(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("Failed to write file: %s", err)
+		}
+		if data, err := ioutil.ReadFile(path); err != nil || string(data) != contents {
+			t.Errorf("Expected '%s', got '%s' (%v)", contents, data, err)
+		}
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected no temporary files to be left behind, got %d files", len(files))
+	}
+}
+
 func TestDownloadFile(t *testing.T) {
 	url := "https://www.w3.org/TR/PNG/iso_8859-1.txt"
 	file, err := ioutil.TempFile("", "clobber-test")
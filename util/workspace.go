@@ -0,0 +1,90 @@
+package util
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// DefaultWorkspace is the name of the workspace living
+// directly in the Clobber root directory
+const DefaultWorkspace = "default"
+
+// workspace is the name of the active workspace
+var workspace = DefaultWorkspace
+
+// workspaceNameRegex matches valid workspace names
+var workspaceNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SetWorkspace changes the active workspace, which affects
+// all paths based on GetClobberPath (sources, logs etc.)
+func SetWorkspace(name string) error {
+	if len(name) == 0 {
+		name = DefaultWorkspace
+	}
+	if err := ValidateWorkspaceName(name); err != nil {
+		return err
+	}
+	workspace = name
+	return nil
+}
+
+// GetWorkspace returns the name of the active workspace
+func GetWorkspace() string {
+	return workspace
+}
+
+// GetWorkspacesPath returns the full path to the directory
+// containing all named (non-default) workspaces
+func GetWorkspacesPath() string {
+	return GetRootPath() + "/workspaces"
+}
+
+// GetWorkspacePath returns the full path to a workspace
+func GetWorkspacePath(name string) string {
+	if len(name) == 0 || name == DefaultWorkspace {
+		return GetRootPath()
+	}
+	return GetWorkspacesPath() + "/" + name
+}
+
+// WorkspaceExists returns true if the workspace directory exists
+func WorkspaceExists(name string) bool {
+	if len(name) == 0 || name == DefaultWorkspace {
+		return true
+	}
+	info, err := os.Stat(GetWorkspacePath(name))
+	return err == nil && info.IsDir()
+}
+
+// ValidateWorkspaceName returns an error if the name can't be used as a workspace
+func ValidateWorkspaceName(name string) error {
+	if !workspaceNameRegex.MatchString(name) {
+		return errors.New("Invalid workspace name '" + name + "' (use letters, numbers, dots, dashes and underscores)")
+	}
+	return nil
+}
+
+// ListWorkspaces returns the names of all workspaces, starting with the default one
+func ListWorkspaces() ([]string, error) {
+	workspaces := []string{DefaultWorkspace}
+
+	workspaceInfos, err := ioutil.ReadDir(GetWorkspacesPath())
+	if os.IsNotExist(err) {
+		return workspaces, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, workspaceInfo := range workspaceInfos {
+		if workspaceInfo.IsDir() && ValidateWorkspaceName(workspaceInfo.Name()) == nil && workspaceInfo.Name() != DefaultWorkspace {
+			names = append(names, workspaceInfo.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return append(workspaces, names...), nil
+}
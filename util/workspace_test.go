@@ -0,0 +1,90 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestGetRootPath(t *testing.T) {
+	defer os.Setenv("CLOBBER_HOME", os.Getenv("CLOBBER_HOME"))
+
+	os.Setenv("CLOBBER_HOME", "")
+	if path := GetRootPath(); path != GetHomePath()+"/.clobber" {
+		t.Errorf("Invalid default root path: %s", path)
+	}
+
+	os.Setenv("CLOBBER_HOME", "/tmp/clobber-test")
+	if path := GetRootPath(); path != "/tmp/clobber-test" {
+		t.Errorf("Invalid CLOBBER_HOME root path: %s", path)
+	}
+}
+
+func TestSetWorkspace(t *testing.T) {
+	defer os.Setenv("CLOBBER_HOME", os.Getenv("CLOBBER_HOME"))
+	defer SetWorkspace(DefaultWorkspace)
+
+	os.Setenv("CLOBBER_HOME", "/tmp/clobber-test")
+	if path := GetClobberPath(); path != "/tmp/clobber-test" {
+		t.Errorf("Invalid default workspace path: %s", path)
+	}
+
+	if err := SetWorkspace("nightly"); err != nil {
+		t.Errorf("Failed to set workspace: %s", err)
+	}
+	if path := GetClobberPath(); path != "/tmp/clobber-test/workspaces/nightly" {
+		t.Errorf("Invalid workspace path: %s", path)
+	}
+	if path := GetSourcePath(); path != "/tmp/clobber-test/workspaces/nightly/src" {
+		t.Errorf("Invalid workspace source path: %s", path)
+	}
+	if path := GetScorePath(); path != "/tmp/clobber-test/.score" {
+		t.Errorf("Score path should not depend on the workspace: %s", path)
+	}
+
+	if err := SetWorkspace(""); err != nil || GetWorkspace() != DefaultWorkspace {
+		t.Errorf("Failed to reset to the default workspace")
+	}
+
+	for _, name := range []string{"..", "../stable", "a/b", ".hidden", "with space"} {
+		if err := SetWorkspace(name); err == nil {
+			t.Errorf("Failed to reject invalid workspace name: %s", name)
+		}
+	}
+}
+
+func TestListWorkspaces(t *testing.T) {
+	defer os.Setenv("CLOBBER_HOME", os.Getenv("CLOBBER_HOME"))
+
+	rootPath, err := ioutil.TempDir("", "clobber-test")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(rootPath)
+	os.Setenv("CLOBBER_HOME", rootPath)
+
+	workspaces, err := ListWorkspaces()
+	if err != nil {
+		t.Errorf("Failed to list workspaces: %s", err)
+	}
+	if len(workspaces) != 1 || workspaces[0] != DefaultWorkspace {
+		t.Errorf("Invalid workspaces: %v", workspaces)
+	}
+
+	for _, name := range []string{"stable", "nightly"} {
+		if err := os.MkdirAll(GetWorkspacePath(name), 0755); err != nil {
+			t.Fatalf("Failed to create workspace: %s", err)
+		}
+	}
+
+	workspaces, err = ListWorkspaces()
+	if err != nil {
+		t.Errorf("Failed to list workspaces: %s", err)
+	}
+	if len(workspaces) != 3 || workspaces[0] != DefaultWorkspace || workspaces[1] != "nightly" || workspaces[2] != "stable" {
+		t.Errorf("Invalid workspaces: %v", workspaces)
+	}
+	if !WorkspaceExists("stable") || WorkspaceExists("missing") {
+		t.Errorf("Failed to check if workspaces exist")
+	}
+}